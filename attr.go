@@ -0,0 +1,111 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+)
+
+// Attr returns the value of the first attribute of start that matches name.
+// If either component of the name is empty it is considered a wildcard.
+func Attr(start xml.StartElement, name xml.Name) (string, bool) {
+	for _, attr := range start.Attr {
+		if nameMatches(name, attr.Name) {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// attrMapper returns a Transformer that replaces the attributes of start
+// elements matching elem with the result of f.
+// f is only called for start elements that match and its result is only used
+// if changed is true.
+func attrMapper(elem xml.Name, f func(start xml.StartElement) (attrs []xml.Attr, changed bool)) Transformer {
+	return func(r xml.TokenReader) xml.TokenReader {
+		return ReaderFunc(func() (xml.Token, error) {
+			tok, err := r.Token()
+			start, ok := tok.(xml.StartElement)
+			if !ok || !nameMatches(elem, start.Name) {
+				return tok, err
+			}
+			if attrs, changed := f(start); changed {
+				start.Attr = attrs
+			}
+			return start, err
+		})
+	}
+}
+
+// SetAttr returns a Transformer that sets an attribute on start elements that
+// match elem.
+// If the element already has an attribute with the same name its value is
+// replaced in place, otherwise the attribute is added after the others.
+// If either component of elem is empty it is considered a wildcard, but the
+// name of attr is used exactly.
+func SetAttr(elem xml.Name, attr xml.Attr) Transformer {
+	return attrMapper(elem, func(start xml.StartElement) ([]xml.Attr, bool) {
+		attrs := make([]xml.Attr, len(start.Attr), len(start.Attr)+1)
+		copy(attrs, start.Attr)
+		found := false
+		for i, a := range attrs {
+			if a.Name == attr.Name {
+				attrs[i].Value = attr.Value
+				found = true
+			}
+		}
+		if !found {
+			attrs = append(attrs, attr)
+		}
+		return attrs, true
+	})
+}
+
+// RenameAttr returns a Transformer that renames attributes matching old on
+// start elements matching elem.
+// If either component of elem or old is empty it is considered a wildcard, and
+// only the first matching attribute is renamed.
+// Any other attribute that already has the new name is removed so that the element
+// does not end up with duplicate attributes.
+func RenameAttr(elem, old, new xml.Name) Transformer {
+	return attrMapper(elem, func(start xml.StartElement) ([]xml.Attr, bool) {
+		if _, ok := Attr(start, old); !ok {
+			return nil, false
+		}
+		attrs := make([]xml.Attr, 0, len(start.Attr))
+		renamed := false
+		for _, a := range start.Attr {
+			switch {
+			case !renamed && nameMatches(old, a.Name):
+				a.Name = new
+				renamed = true
+			case a.Name == new:
+				continue
+			}
+			attrs = append(attrs, a)
+		}
+		return attrs, true
+	})
+}
+
+// MapAttr returns a Transformer that replaces attributes matching name with the
+// result of calling f.
+// If either component of the name is empty it is considered a wildcard.
+// The start element passed to f has not been modified.
+func MapAttr(name xml.Name, f func(start xml.StartElement, attr xml.Attr) xml.Attr) Transformer {
+	return attrMapper(xml.Name{}, func(start xml.StartElement) ([]xml.Attr, bool) {
+		if _, ok := Attr(start, name); !ok {
+			return nil, false
+		}
+		attrs := make([]xml.Attr, len(start.Attr))
+		for i, a := range start.Attr {
+			if nameMatches(name, a.Name) {
+				a = f(start, a)
+			}
+			attrs[i] = a
+		}
+		return attrs, true
+	})
+}
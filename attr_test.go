@@ -0,0 +1,125 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+func TestAttr(t *testing.T) {
+	start := xml.StartElement{
+		Name: xml.Name{Local: "iq"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "type"}, Value: "get"},
+			{Name: xml.Name{Space: "urn:example", Local: "id"}, Value: "ns"},
+			{Name: xml.Name{Local: "id"}, Value: "123"},
+		},
+	}
+	for i, tc := range [...]struct {
+		name  xml.Name
+		value string
+		ok    bool
+	}{
+		0: {name: xml.Name{Local: "type"}, value: "get", ok: true},
+		1: {name: xml.Name{Local: "id"}, value: "ns", ok: true},
+		2: {name: xml.Name{Space: "urn:example", Local: "id"}, value: "ns", ok: true},
+		3: {name: xml.Name{Space: "urn:other", Local: "id"}},
+		4: {name: xml.Name{Space: "urn:example"}, value: "ns", ok: true},
+		5: {name: xml.Name{Local: "to"}},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			value, ok := xmlstream.Attr(start, tc.name)
+			if value != tc.value || ok != tc.ok {
+				t.Errorf("wrong result: want=(%q, %t), got=(%q, %t)", tc.value, tc.ok, value, ok)
+			}
+		})
+	}
+}
+
+var attrTransformTests = [...]struct {
+	t   xmlstream.Transformer
+	in  string
+	out string
+}{
+	0: {
+		t:   xmlstream.SetAttr(xml.Name{Local: "iq"}, xml.Attr{Name: xml.Name{Local: "type"}, Value: "set"}),
+		in:  `<iq type="get" id="1"><query type="get"></query></iq><iq></iq>`,
+		out: `<iq type="set" id="1"><query type="get"></query></iq><iq type="set"></iq>`,
+	},
+	1: {
+		t:   xmlstream.SetAttr(xml.Name{Space: "jabber:client"}, xml.Attr{Name: xml.Name{Local: "to"}, Value: "a@example.net"}),
+		in:  `<message xmlns="jabber:client"><body xmlns="urn:other"></body></message>`,
+		out: `<message xmlns="jabber:client" to="a@example.net"><body xmlns="urn:other"></body></message>`,
+	},
+	2: {
+		t:   xmlstream.RenameAttr(xml.Name{}, xml.Name{Local: "from"}, xml.Name{Local: "to"}),
+		in:  `<a from="x" to="y"></a><b from="z"></b><c></c>`,
+		out: `<a to="x"></a><b to="z"></b><c></c>`,
+	},
+	3: {
+		t:   xmlstream.RenameAttr(xml.Name{Local: "b"}, xml.Name{Local: "from"}, xml.Name{Local: "to"}),
+		in:  `<a from="x"><b from="z"></b></a>`,
+		out: `<a from="x"><b to="z"></b></a>`,
+	},
+	4: {
+		t:   xmlstream.RenameAttr(xml.Name{}, xml.Name{Space: "urn:old"}, xml.Name{Local: "lang"}),
+		in:  `<a xmlns:o="urn:old" o:lang="en" o:dir="ltr"></a>`,
+		out: `<a xmlns:o="urn:old" lang="en" o:dir="ltr"></a>`,
+	},
+	5: {
+		t: xmlstream.MapAttr(xml.Name{Local: "id"}, func(start xml.StartElement, attr xml.Attr) xml.Attr {
+			attr.Value = start.Name.Local + "-" + attr.Value
+			return attr
+		}),
+		in:  `<iq id="1" type="get"><query id="2"></query></iq>`,
+		out: `<iq id="iq-1" type="get"><query id="query-2"></query></iq>`,
+	},
+	6: {
+		t:   xmlstream.SetAttr(xml.Name{}, xml.Attr{Name: xml.Name{Local: "y"}, Value: "9"}),
+		in:  `<a x="1" y="2" z="3"></a><b z="3" x="1"></b>`,
+		out: `<a x="1" y="9" z="3"></a><b z="3" x="1" y="9"></b>`,
+	},
+}
+
+func TestAttrTransformers(t *testing.T) {
+	for i, tc := range attrTransformTests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r := tc.t(xml.NewDecoder(strings.NewReader(tc.in)))
+			var buf strings.Builder
+			e := xmlstream.XMLWriter(&buf)
+			if _, err := xmlstream.Copy(e, r); err != nil {
+				t.Fatalf("error copying tokens: %v", err)
+			}
+			if err := e.Flush(); err != nil {
+				t.Fatalf("error flushing: %v", err)
+			}
+			if out := buf.String(); out != tc.out {
+				t.Errorf("wrong output:\nwant=%s,\n got=%s", tc.out, out)
+			}
+		})
+	}
+}
+
+func TestSetAttrDoesNotModifyInput(t *testing.T) {
+	attrs := []xml.Attr{{Name: xml.Name{Local: "type"}, Value: "get"}}
+	start := xml.StartElement{Name: xml.Name{Local: "iq"}, Attr: attrs}
+	r := xmlstream.SetAttr(xml.Name{}, xml.Attr{Name: xml.Name{Local: "type"}, Value: "set"})(xmlstream.Token(start))
+	tok, err := r.Token()
+	if err != nil && err != io.EOF {
+		t.Fatalf("error reading token: %v", err)
+	}
+	if v, _ := xmlstream.Attr(tok.(xml.StartElement), xml.Name{Local: "type"}); v != "set" {
+		t.Errorf("attribute was not set, got %q", v)
+	}
+	if attrs[0].Value != "get" {
+		t.Errorf("input attributes were modified")
+	}
+}
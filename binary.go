@@ -0,0 +1,289 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The binary format is a sequence of tokens, each of which is encoded as a kind
+// byte, the length of the payload as a uvarint, and the payload.
+// Strings used in names are sent once and then referenced by their index in a
+// string table that both sides build in the same order.
+//
+//	StartElement: name, uvarint attr count, attrs (name, value)
+//	EndElement:   name
+//	CharData:     raw bytes
+//	Comment:      raw bytes
+//	ProcInst:     target (string ref), raw bytes
+//	Directive:    raw bytes
+//
+// A name is two string references (space then local).
+// A string reference is a uvarint table index plus one, or 0 followed by a
+// uvarint length and the string itself, in which case the string is added to
+// the table if there is room.
+// Attribute values are sent as a uvarint length and the string.
+const (
+	binStart byte = iota + 1
+	binEnd
+	binCharData
+	binComment
+	binProcInst
+	binDirective
+)
+
+// binaryTableSize is the maximum number of strings that the encoder and decoder
+// will remember.
+const binaryTableSize = 1 << 12
+
+var errBinaryToken = errors.New("xmlstream: malformed binary token")
+
+// BinaryWriter returns a TokenWriter that writes tokens to w in a compact
+// binary format that can be read with BinaryReader.
+// It is intended for passing tokens between processes without having to encode
+// and parse XML.
+// Output is buffered and must be flushed.
+func BinaryWriter(w io.Writer) TokenWriteFlusher {
+	return &binaryWriter{
+		w:     bufio.NewWriter(w),
+		table: make(map[string]uint64),
+	}
+}
+
+type binaryWriter struct {
+	w       *bufio.Writer
+	table   map[string]uint64
+	payload []byte
+	head    [binary.MaxVarintLen64 + 1]byte
+}
+
+func (b *binaryWriter) str(s string) {
+	if idx, ok := b.table[s]; ok {
+		b.payload = binary.AppendUvarint(b.payload, idx+1)
+		return
+	}
+	if len(b.table) < binaryTableSize {
+		b.table[s] = uint64(len(b.table))
+	}
+	b.payload = append(b.payload, 0)
+	b.bytes(s)
+}
+
+func (b *binaryWriter) bytes(s string) {
+	b.payload = binary.AppendUvarint(b.payload, uint64(len(s)))
+	b.payload = append(b.payload, s...)
+}
+
+func (b *binaryWriter) name(n xml.Name) {
+	b.str(n.Space)
+	b.str(n.Local)
+}
+
+func (b *binaryWriter) EncodeToken(t xml.Token) error {
+	var kind byte
+	b.payload = b.payload[:0]
+	switch tok := t.(type) {
+	case xml.StartElement:
+		kind = binStart
+		b.name(tok.Name)
+		b.payload = binary.AppendUvarint(b.payload, uint64(len(tok.Attr)))
+		for _, attr := range tok.Attr {
+			b.name(attr.Name)
+			b.bytes(attr.Value)
+		}
+	case xml.EndElement:
+		kind = binEnd
+		b.name(tok.Name)
+	case xml.CharData:
+		kind = binCharData
+		b.payload = append(b.payload, tok...)
+	case xml.Comment:
+		kind = binComment
+		b.payload = append(b.payload, tok...)
+	case xml.ProcInst:
+		kind = binProcInst
+		b.str(tok.Target)
+		b.payload = append(b.payload, tok.Inst...)
+	case xml.Directive:
+		kind = binDirective
+		b.payload = append(b.payload, tok...)
+	default:
+		return fmt.Errorf("xmlstream: cannot encode token of type %T", t)
+	}
+
+	b.head[0] = kind
+	n := binary.PutUvarint(b.head[1:], uint64(len(b.payload)))
+	if _, err := b.w.Write(b.head[:n+1]); err != nil {
+		return err
+	}
+	_, err := b.w.Write(b.payload)
+	return err
+}
+
+func (b *binaryWriter) Flush() error {
+	return b.w.Flush()
+}
+
+// BinaryReader returns a reader that decodes tokens written by BinaryWriter
+// from r.
+func BinaryReader(r io.Reader) xml.TokenReader {
+	return &binaryReader{r: bufio.NewReader(r)}
+}
+
+type binaryReader struct {
+	r       *bufio.Reader
+	table   []string
+	payload bytes.Buffer
+	p       []byte
+	err     error
+}
+
+func (b *binaryReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(b.p)
+	if n <= 0 {
+		return 0, errBinaryToken
+	}
+	b.p = b.p[n:]
+	return v, nil
+}
+
+func (b *binaryReader) bytes() ([]byte, error) {
+	l, err := b.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(b.p)) < l {
+		return nil, errBinaryToken
+	}
+	s := b.p[:l]
+	b.p = b.p[l:]
+	return s, nil
+}
+
+func (b *binaryReader) str() (string, error) {
+	idx, err := b.uvarint()
+	if err != nil {
+		return "", err
+	}
+	if idx > 0 {
+		if idx > uint64(len(b.table)) {
+			return "", errBinaryToken
+		}
+		return b.table[idx-1], nil
+	}
+	s, err := b.bytes()
+	if err != nil {
+		return "", err
+	}
+	str := string(s)
+	if len(b.table) < binaryTableSize {
+		b.table = append(b.table, str)
+	}
+	return str, nil
+}
+
+func (b *binaryReader) name() (n xml.Name, err error) {
+	n.Space, err = b.str()
+	if err != nil {
+		return n, err
+	}
+	n.Local, err = b.str()
+	return n, err
+}
+
+func (b *binaryReader) rest() []byte {
+	p := make([]byte, len(b.p))
+	copy(p, b.p)
+	b.p = nil
+	return p
+}
+
+func (b *binaryReader) Token() (xml.Token, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	t, err := b.token()
+	if err != nil {
+		b.err = err
+	}
+	return t, err
+}
+
+func (b *binaryReader) token() (xml.Token, error) {
+	kind, err := b.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	l, err := binary.ReadUvarint(b.r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	b.payload.Reset()
+	if _, err := io.CopyN(&b.payload, b.r, int64(l)); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	b.p = b.payload.Bytes()
+
+	switch kind {
+	case binStart:
+		var start xml.StartElement
+		start.Name, err = b.name()
+		if err != nil {
+			return nil, err
+		}
+		n, err := b.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		// Each attribute takes at least 3 bytes.
+		if n > uint64(len(b.p)/3) {
+			return nil, errBinaryToken
+		}
+		start.Attr = make([]xml.Attr, 0, n)
+		for i := uint64(0); i < n; i++ {
+			var attr xml.Attr
+			attr.Name, err = b.name()
+			if err != nil {
+				return nil, err
+			}
+			v, err := b.bytes()
+			if err != nil {
+				return nil, err
+			}
+			attr.Value = string(v)
+			start.Attr = append(start.Attr, attr)
+		}
+		return start, nil
+	case binEnd:
+		name, err := b.name()
+		return xml.EndElement{Name: name}, err
+	case binCharData:
+		return xml.CharData(b.rest()), nil
+	case binComment:
+		return xml.Comment(b.rest()), nil
+	case binProcInst:
+		target, err := b.str()
+		if err != nil {
+			return nil, err
+		}
+		return xml.ProcInst{Target: target, Inst: b.rest()}, nil
+	case binDirective:
+		return xml.Directive(b.rest()), nil
+	}
+	return nil, errBinaryToken
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
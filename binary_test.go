@@ -0,0 +1,161 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+var binaryTokens = []xml.Token{
+	xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0"`)},
+	xml.Directive("DOCTYPE a"),
+	xml.StartElement{
+		Name: xml.Name{Space: "urn:a", Local: "a"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "xmlns"}, Value: "urn:a"},
+			{Name: xml.Name{Space: "urn:b", Local: "b"}, Value: ""},
+		},
+	},
+	xml.CharData("text & <stuff>"),
+	xml.Comment(" comment "),
+	xml.StartElement{Name: xml.Name{Space: "urn:a", Local: "a"}, Attr: []xml.Attr{}},
+	xml.CharData{},
+	xml.EndElement{Name: xml.Name{Space: "urn:a", Local: "a"}},
+	xml.ProcInst{Target: "pi"},
+	xml.EndElement{Name: xml.Name{Space: "urn:a", Local: "a"}},
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := xmlstream.BinaryWriter(&buf)
+	for _, tok := range binaryTokens {
+		if err := w.EncodeToken(tok); err != nil {
+			t.Fatalf("error encoding %v: %v", tok, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+
+	toks, err := xmlstream.ReadAll(xmlstream.BinaryReader(&buf))
+	if err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	if len(toks) != len(binaryTokens) {
+		t.Fatalf("wrong number of tokens: want=%d, got=%d", len(binaryTokens), len(toks))
+	}
+	for i, tok := range toks {
+		// Nil and empty slices are encoded the same way.
+		want := binaryTokens[i]
+		if pi, ok := want.(xml.ProcInst); ok && pi.Inst == nil {
+			pi.Inst = []byte{}
+			want = pi
+		}
+		if !reflect.DeepEqual(tok, want) {
+			t.Errorf("wrong token %d:\nwant=%#v,\n got=%#v", i, want, tok)
+		}
+	}
+}
+
+func TestBinaryTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	w := xmlstream.BinaryWriter(&buf)
+	// Record where each token ends, since truncating the stream at a token
+	// boundary results in a valid stream.
+	boundary := make(map[int]bool)
+	d := xml.NewDecoder(strings.NewReader(`<a b="c">d</a>`))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		if err := w.EncodeToken(tok); err != nil {
+			t.Fatalf("error encoding: %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("error flushing: %v", err)
+		}
+		boundary[buf.Len()] = true
+	}
+	b := buf.Bytes()
+	for i := 1; i < len(b); i++ {
+		_, err := xmlstream.ReadAll(xmlstream.BinaryReader(bytes.NewReader(b[:i])))
+		switch {
+		case boundary[i] && err != nil:
+			t.Errorf("unexpected error reading %d bytes of %d: %v", i, len(b), err)
+		case !boundary[i] && err == nil:
+			t.Errorf("expected error reading %d bytes of %d", i, len(b))
+		}
+	}
+}
+
+func benchmarkStream() string {
+	var s strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&s, `<message to="juliet@example.com" from="romeo@example.net" id="%d" type="chat"><body>Art thou not Romeo, and a Montague?</body><thread>e0ffe42b28561960c6b12b944a092794b9683a38</thread></message>`, i)
+	}
+	return s.String()
+}
+
+func BenchmarkBinaryCopy(b *testing.B) {
+	toks, err := xmlstream.ReadAll(xml.NewDecoder(strings.NewReader(benchmarkStream())))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		w := xmlstream.BinaryWriter(&buf)
+		for _, tok := range toks {
+			if err := w.EncodeToken(tok); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := xmlstream.Copy(xmlstream.Discard(), xmlstream.BinaryReader(&buf)); err != nil && err != io.EOF {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkXMLCopy(b *testing.B) {
+	toks, err := xmlstream.ReadAll(xml.NewDecoder(strings.NewReader(benchmarkStream())))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		e := xml.NewEncoder(&buf)
+		for _, tok := range toks {
+			if err := e.EncodeToken(tok); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := e.Flush(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := xmlstream.Copy(xmlstream.Discard(), xml.NewDecoder(&buf)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
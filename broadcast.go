@@ -0,0 +1,226 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrSlowConsumer is returned by the readers of a Broadcaster when a reader
+// falls too far behind the others.
+var ErrSlowConsumer = errors.New("xmlstream: broadcast reader fell too far behind")
+
+// SlowPolicy determines what a Broadcaster does when a reader's buffer is full.
+type SlowPolicy uint8
+
+// A list of slow consumer policies.
+const (
+	// SlowBlock stops reading from the source until every reader has room in its
+	// buffer.
+	// This is the default.
+	SlowBlock SlowPolicy = iota
+
+	// SlowDrop removes readers with full buffers from the Broadcaster.
+	// Once a dropped reader has returned its buffered tokens, it returns
+	// ErrSlowConsumer.
+	SlowDrop
+
+	// SlowError stops the broadcast when any reader's buffer is full.
+	// Once each reader has returned its buffered tokens, it returns
+	// ErrSlowConsumer.
+	SlowError
+)
+
+// BroadcastOption is used to configure a Broadcaster.
+type BroadcastOption func(*Broadcaster)
+
+// BroadcastLimit sets the maximum number of tokens that are buffered for each
+// reader.
+// If n <= 0, which is the default, buffers are unlimited.
+func BroadcastLimit(n int) BroadcastOption {
+	return func(b *Broadcaster) {
+		b.limit = n
+	}
+}
+
+// BroadcastPolicy sets the policy for handling readers that have filled their
+// buffer.
+func BroadcastPolicy(p SlowPolicy) BroadcastOption {
+	return func(b *Broadcaster) {
+		b.policy = p
+	}
+}
+
+// Broadcaster splits a single xml.TokenReader into many independent readers
+// that each see the same tokens.
+//
+// Tokens are read from the source whenever a reader has no more buffered tokens
+// and are copied into the buffer of every subscribed reader, so readers are
+// free to modify the tokens they receive.
+// With the SlowBlock policy and a buffer limit, readers must be used from
+// separate goroutines or the fastest reader will block forever waiting for the
+// others to catch up.
+type Broadcaster struct {
+	r       xml.TokenReader
+	limit   int
+	policy  SlowPolicy
+	mu      sync.Mutex
+	cond    sync.Cond
+	subs    []*broadcastReader
+	reading bool
+	err     error
+}
+
+// NewBroadcaster returns a Broadcaster that reads from r.
+func NewBroadcaster(r xml.TokenReader, opts ...BroadcastOption) *Broadcaster {
+	b := &Broadcaster{r: r}
+	b.cond.L = &b.mu
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Broadcast returns n readers that each see every token read from r.
+// It is a convenience function that creates a Broadcaster and subscribes to it
+// n times.
+func Broadcast(r xml.TokenReader, n int, opts ...BroadcastOption) []TokenReadCloser {
+	b := NewBroadcaster(r, opts...)
+	readers := make([]TokenReadCloser, n)
+	for i := range readers {
+		readers[i] = b.Subscribe()
+	}
+	return readers
+}
+
+// Subscribe returns a new reader that sees every token read from the source
+// after the call to Subscribe.
+// Subscribing in the middle of an element means that the reader will see an
+// incomplete element.
+// Closing the reader unsubscribes it.
+func (b *Broadcaster) Subscribe() TokenReadCloser {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &broadcastReader{b: b, err: b.err}
+	if s.err == nil {
+		b.subs = append(b.subs, s)
+	}
+	return s
+}
+
+// full reports whether any reader has a full buffer.
+func (b *Broadcaster) full() bool {
+	if b.limit <= 0 {
+		return false
+	}
+	for _, s := range b.subs {
+		if len(s.buf) >= b.limit {
+			return true
+		}
+	}
+	return false
+}
+
+// remove unsubscribes s and sets its error.
+// It must be called with the lock held.
+func (b *Broadcaster) remove(s *broadcastReader, err error) {
+	for i, sub := range b.subs {
+		if sub == s {
+			b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+			break
+		}
+	}
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// fill reads a token from the source and copies it to every reader.
+// It must be called with the lock held, and releases it while reading.
+func (b *Broadcaster) fill() {
+	b.reading = true
+	if b.policy == SlowBlock {
+		for b.full() {
+			b.cond.Wait()
+		}
+	}
+	b.mu.Unlock()
+	tok, err := b.r.Token()
+	b.mu.Lock()
+	b.reading = false
+	defer b.cond.Broadcast()
+
+	if tok == nil && err == nil {
+		err = io.EOF
+	}
+	if tok != nil {
+		if b.full() {
+			switch b.policy {
+			case SlowDrop:
+				for _, s := range append([]*broadcastReader(nil), b.subs...) {
+					if len(s.buf) >= b.limit {
+						b.remove(s, ErrSlowConsumer)
+					}
+				}
+			case SlowError:
+				err = ErrSlowConsumer
+				tok = nil
+			}
+		}
+		for _, s := range b.subs {
+			if tok != nil {
+				s.buf = append(s.buf, xml.CopyToken(tok))
+			}
+		}
+	}
+	if err != nil {
+		b.err = err
+		for _, s := range append([]*broadcastReader(nil), b.subs...) {
+			b.remove(s, err)
+		}
+	}
+}
+
+type broadcastReader struct {
+	b   *Broadcaster
+	buf []xml.Token
+	err error
+}
+
+func (s *broadcastReader) Token() (xml.Token, error) {
+	b := s.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		if len(s.buf) > 0 {
+			tok := s.buf[0]
+			s.buf[0] = nil
+			s.buf = s.buf[1:]
+			b.cond.Broadcast()
+			return tok, nil
+		}
+		if s.err != nil {
+			return nil, s.err
+		}
+		if b.reading {
+			b.cond.Wait()
+			continue
+		}
+		b.fill()
+	}
+}
+
+func (s *broadcastReader) Close() error {
+	b := s.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(s, io.EOF)
+	s.buf = nil
+	b.cond.Broadcast()
+	return nil
+}
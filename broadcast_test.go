@@ -0,0 +1,152 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+const broadcastStream = `<message><body>one</body></message><message><body>two</body></message>`
+
+func encodeAll(r xml.TokenReader) (string, error) {
+	var buf strings.Builder
+	e := xml.NewEncoder(&buf)
+	_, err := xmlstream.Copy(e, r)
+	if flushErr := e.Flush(); err == nil {
+		err = flushErr
+	}
+	return buf.String(), err
+}
+
+func TestBroadcast(t *testing.T) {
+	const in = `<message id="1"><body>one</body></message><message id="2"><body>two</body></message>`
+	readers := xmlstream.Broadcast(xml.NewDecoder(strings.NewReader(in)), 3)
+
+	// Modifying the attributes and text of tokens received by one reader must
+	// not affect the others.
+	var n int
+	for {
+		tok, err := readers[0].Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("error reading from first reader: %v", err)
+		}
+		n++
+		switch tt := tok.(type) {
+		case xml.StartElement:
+			for i := range tt.Attr {
+				tt.Attr[i].Value = "changed"
+			}
+		case xml.CharData:
+			for i := range tt {
+				tt[i] = 'x'
+			}
+		}
+	}
+	if n != 10 {
+		t.Errorf("wrong number of tokens: want=10, got=%d", n)
+	}
+
+	for _, r := range readers[1:] {
+		out, err := encodeAll(r)
+		if err != nil {
+			t.Fatalf("error reading: %v", err)
+		}
+		if out != in {
+			t.Errorf("wrong output: want=%q, got=%q", in, out)
+		}
+	}
+}
+
+func TestBroadcastSubscribe(t *testing.T) {
+	b := xmlstream.NewBroadcaster(xml.NewDecoder(strings.NewReader(broadcastStream)))
+	r1 := b.Subscribe()
+	for i := 0; i < 5; i++ {
+		if _, err := r1.Token(); err != nil {
+			t.Fatalf("error reading token: %v", err)
+		}
+	}
+	r2 := b.Subscribe()
+	out, err := encodeAll(r2)
+	if err != nil {
+		t.Fatalf("error reading: %v", err)
+	}
+	if want := `<message><body>two</body></message>`; out != want {
+		t.Errorf("wrong output: want=%q, got=%q", want, out)
+	}
+	if err := r1.Close(); err != nil {
+		t.Fatalf("error closing: %v", err)
+	}
+	if tok, err := r1.Token(); tok != nil || err == nil {
+		t.Errorf("expected closed reader to return an error, got %v, %v", tok, err)
+	}
+}
+
+func TestBroadcastSlowDrop(t *testing.T) {
+	readers := xmlstream.Broadcast(
+		xml.NewDecoder(strings.NewReader(broadcastStream)), 2,
+		xmlstream.BroadcastLimit(2),
+		xmlstream.BroadcastPolicy(xmlstream.SlowDrop),
+	)
+	out, err := encodeAll(readers[0])
+	if err != nil {
+		t.Fatalf("unexpected error from fast reader: %v", err)
+	}
+	if out != broadcastStream {
+		t.Errorf("wrong output: want=%q, got=%q", broadcastStream, out)
+	}
+	toks, err := xmlstream.ReadAll(readers[1])
+	if err != xmlstream.ErrSlowConsumer {
+		t.Errorf("wrong error from slow reader: want=%v, got=%v", xmlstream.ErrSlowConsumer, err)
+	}
+	if len(toks) != 2 {
+		t.Errorf("expected slow reader to return its buffered tokens, got %v", toks)
+	}
+}
+
+func TestBroadcastSlowError(t *testing.T) {
+	readers := xmlstream.Broadcast(
+		xml.NewDecoder(strings.NewReader(broadcastStream)), 2,
+		xmlstream.BroadcastLimit(2),
+		xmlstream.BroadcastPolicy(xmlstream.SlowError),
+	)
+	toks, err := xmlstream.ReadAll(readers[0])
+	if err != xmlstream.ErrSlowConsumer {
+		t.Errorf("wrong error: want=%v, got=%v", xmlstream.ErrSlowConsumer, err)
+	}
+	if len(toks) != 2 {
+		t.Errorf("wrong number of tokens: want=2, got=%d", len(toks))
+	}
+}
+
+func TestBroadcastSlowBlock(t *testing.T) {
+	readers := xmlstream.Broadcast(
+		xml.NewDecoder(strings.NewReader(broadcastStream)), 3,
+		xmlstream.BroadcastLimit(1),
+	)
+	var wg sync.WaitGroup
+	for _, r := range readers {
+		wg.Add(1)
+		go func(r xml.TokenReader) {
+			defer wg.Done()
+			out, err := encodeAll(r)
+			if err != nil {
+				t.Errorf("error reading: %v", err)
+			}
+			if out != broadcastStream {
+				t.Errorf("wrong output: want=%q, got=%q", broadcastStream, out)
+			}
+		}(r)
+	}
+	wg.Wait()
+}
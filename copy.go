@@ -20,7 +20,12 @@ import (
 // If src implements the WriterTo interface, the copy is implemented by calling
 // src.WriteXML(dst). Otherwise, if dst implements the ReaderFrom interface, the
 // copy is implemented by calling dst.ReadXML(src).
+// As an exception, if dst is a SyncTokenWriter the copy is always implemented
+// by calling dst.ReadXML(src) so that the tokens are written atomically.
 func Copy(dst TokenWriter, src xml.TokenReader) (n int, err error) {
+	if sw, ok := dst.(*SyncTokenWriter); ok {
+		return sw.ReadXML(src)
+	}
 	if wt, ok := src.(WriterTo); ok {
 		return wt.WriteXML(dst)
 	}
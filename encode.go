@@ -0,0 +1,637 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var errUnclosed = errors.New("xmlstream: unclosed elements")
+
+// XMLWriterOption configures an XMLWriter.
+type XMLWriterOption func(*xmlWriter)
+
+// RawNames treats the Space field of names as a prefix instead of a namespace
+// URI, as in tokens returned by the RawToken method of xml.Decoder.
+// Names are written exactly as they appear in the tokens and no namespace
+// declarations are added or removed.
+func RawNames() XMLWriterOption {
+	return func(w *xmlWriter) {
+		w.raw = true
+	}
+}
+
+// NoValidate disables checking that names are valid, that end elements match
+// their start elements, and that comments and processing instructions can be
+// encoded.
+// Text and attribute values are still escaped.
+func NoValidate() XMLWriterOption {
+	return func(w *xmlWriter) {
+		w.noValidate = true
+	}
+}
+
+// SelfClose writes elements that have no content as self-closing tags.
+func SelfClose() XMLWriterOption {
+	return func(w *xmlWriter) {
+		w.selfClose = true
+	}
+}
+
+// WrapAttr writes every attribute of a start element on its own line if the
+// start tag would otherwise extend past the given column.
+// The first attribute stays on the same line as the element name and the others
+// are aligned with it.
+// Columns are counted in characters from the start of the line.
+// If width <= 0 attributes are never wrapped.
+func WrapAttr(width int) XMLWriterOption {
+	return func(w *xmlWriter) {
+		w.wrap = width
+	}
+}
+
+// LineWidth starts a new line before any attribute that would otherwise extend
+// past the given column, aligning it with the first attribute of the element.
+// Names, text and the first attribute of each element are never broken up, so
+// lines may still be longer than width.
+// If width <= 0 the line width is unlimited.
+func LineWidth(width int) XMLWriterOption {
+	return func(w *xmlWriter) {
+		w.width = width
+	}
+}
+
+// XMLWriter returns a TokenWriter that encodes tokens as XML to w.
+// It is a faster alternative to xml.Encoder with predictable namespace
+// handling.
+//
+// By default names are expected to contain namespace URIs, as in tokens
+// returned by the Token method of xml.Decoder.
+// An element is written without a prefix if its namespace is the default
+// namespace, with a prefix if one is already bound to its namespace, and with a
+// new default namespace declaration otherwise.
+// Namespace declarations in a start element's attributes are written as is and
+// are taken into account when picking prefixes, so decoding and re-encoding a
+// document does not add redundant declarations.
+// If a start element declares a default namespace other than its own, it is
+// written with a prefix instead, or, if it is not in a namespace, the
+// declaration is replaced with xmlns="".
+// Namespaced attributes use an existing prefix or declare a new one.
+//
+// Output is buffered and must be flushed.
+// Close flushes the output and returns an error if any elements are still open,
+// but does not close w.
+func XMLWriter(w io.Writer, opts ...XMLWriterOption) TokenWriteFlushCloser {
+	xw := &xmlWriter{
+		w:     bufio.NewWriter(w),
+		valid: make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(xw)
+	}
+	xw.track = xw.wrap > 0 || xw.width > 0
+	return xw
+}
+
+// xmlElem is an element that has been written but not closed.
+type xmlElem struct {
+	name     xml.Name
+	qname    string
+	bindings int
+}
+
+type xmlWriter struct {
+	w          *bufio.Writer
+	raw        bool
+	noValidate bool
+	selfClose  bool
+	valid      map[string]struct{}
+	stack      []xmlElem
+	ns         nsScope
+	decls      []nsBinding
+	attrs      []string
+	open       bool
+	written    bool
+	gen        int
+
+	// Layout of start tags.
+	// The current column and the whitespace at the start of the current line are
+	// only tracked if track is set.
+	wrap  int
+	width int
+	track bool
+	col   int
+	lead  []byte
+	out   []xmlAttrOut
+}
+
+// xmlAttrOut is an attribute or namespace declaration that is about to be
+// written along with the number of characters it takes up, including the space
+// before it.
+type xmlAttrOut struct {
+	name  string
+	value string
+	width int
+}
+
+// declare binds a new prefix or default namespace and records that it must be
+// written.
+func (w *xmlWriter) declare(prefix, uri string) {
+	w.ns.bind(prefix, uri)
+	w.decls = append(w.decls, nsBinding{prefix: prefix, uri: uri})
+}
+
+func (w *xmlWriter) checkName(n string) error {
+	if w.noValidate {
+		return nil
+	}
+	if _, ok := w.valid[n]; ok {
+		return nil
+	}
+	if !isName(n) {
+		return fmt.Errorf("xmlstream: invalid XML name %q", n)
+	}
+	w.valid[n] = struct{}{}
+	return nil
+}
+
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || r == ':' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || r == '·' || unicode.IsDigit(r) ||
+			unicode.In(r, unicode.Mn, unicode.Mc, unicode.Other_ID_Continue)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func joinName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+// closeStart finishes a start tag if one is still open.
+func (w *xmlWriter) closeStart() {
+	if w.open {
+		w.w.WriteByte('>')
+		w.col++
+		w.open = false
+	}
+}
+
+// advance updates the current column after s has been written, escaped
+// according to special if it is not nil.
+func (w *xmlWriter) advance(s string, special *[256]bool) {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+		w.col = 0
+		w.lead = w.lead[:0]
+	}
+	if w.col == len(w.lead) {
+		n := 0
+		for n < len(s) && (s[n] == ' ' || s[n] == '\t') {
+			n++
+		}
+		w.lead = append(w.lead, s[:n]...)
+	}
+	w.col += textWidth(s, special)
+}
+
+// textWidth returns the number of characters that s takes up once it has been
+// escaped according to special, if special is not nil.
+func textWidth(s string, special *[256]bool) int {
+	var n int
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c&0xc0 == 0x80:
+			// Continuation bytes are counted along with the first byte of the rune.
+		case special == nil || !special[c]:
+			n++
+		case c == '<' || c == '>':
+			n += len("&lt;")
+		case c == '&' || c == '\r' || c == '"' || c == '\n' || c == '\t':
+			n += len("&amp;")
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+func (w *xmlWriter) EncodeToken(t xml.Token) error {
+	switch tok := t.(type) {
+	case xml.StartElement:
+		return w.writeStart(tok)
+	case xml.EndElement:
+		return w.writeEnd(tok)
+	case xml.CharData:
+		w.closeStart()
+		escapeText(w.w, tok, false)
+		if w.track {
+			w.advance(string(tok), &textSpecial)
+		}
+	case xml.Comment:
+		if !w.noValidate && (strings.Contains(string(tok), "--") || strings.HasSuffix(string(tok), "-")) {
+			return errors.New(`xmlstream: comments must not contain "--"`)
+		}
+		w.closeStart()
+		w.w.WriteString("<!--")
+		w.w.Write(tok)
+		w.w.WriteString("-->")
+		if w.track {
+			w.advance("<!--"+string(tok)+"-->", nil)
+		}
+	case xml.ProcInst:
+		if !w.noValidate {
+			if err := w.checkName(tok.Target); err != nil {
+				return err
+			}
+			if tok.Target == "xml" && w.written {
+				return errors.New("xmlstream: the XML declaration must be the first token")
+			}
+			if strings.Contains(string(tok.Inst), "?>") {
+				return errors.New(`xmlstream: processing instructions must not contain "?>"`)
+			}
+		}
+		w.closeStart()
+		w.w.WriteString("<?")
+		w.w.WriteString(tok.Target)
+		if len(tok.Inst) > 0 {
+			w.w.WriteByte(' ')
+			w.w.Write(tok.Inst)
+		}
+		w.w.WriteString("?>")
+		if w.track {
+			w.advance("<?"+tok.Target+" "+string(tok.Inst)+"?>", nil)
+		}
+	case xml.Directive:
+		w.closeStart()
+		w.w.WriteString("<!")
+		w.w.Write(tok)
+		w.w.WriteByte('>')
+		if w.track {
+			w.advance("<!"+string(tok)+">", nil)
+		}
+	default:
+		return fmt.Errorf("xmlstream: cannot encode token of type %T", t)
+	}
+	w.written = true
+	return nil
+}
+
+func (w *xmlWriter) writeStart(start xml.StartElement) error {
+	w.closeStart()
+	el := xmlElem{name: start.Name, bindings: w.ns.mark()}
+	w.decls = w.decls[:0]
+	w.attrs = w.attrs[:0]
+
+	if w.raw {
+		el.qname = joinName(start.Name.Space, start.Name.Local)
+		if err := w.checkName(el.qname); err != nil {
+			return err
+		}
+		for _, attr := range start.Attr {
+			name := joinName(attr.Name.Space, attr.Name.Local)
+			if err := w.checkName(name); err != nil {
+				return err
+			}
+			w.attrs = append(w.attrs, name)
+		}
+	} else {
+		if err := w.resolve(&el, start); err != nil {
+			w.ns.reset(el.bindings)
+			return err
+		}
+	}
+
+	w.w.WriteByte('<')
+	w.w.WriteString(el.qname)
+	if w.track {
+		w.writeAttrLayout(el.qname, start)
+	} else {
+		w.writeAttrs(start)
+	}
+	if w.selfClose {
+		w.open = true
+	} else {
+		w.w.WriteByte('>')
+		w.col++
+	}
+	w.stack = append(w.stack, el)
+	w.written = true
+	return nil
+}
+
+// writeAttrs writes the namespace declarations and attributes of a start
+// element.
+func (w *xmlWriter) writeAttrs(start xml.StartElement) {
+	for _, decl := range w.decls {
+		w.w.WriteString(" xmlns")
+		if decl.prefix != "" {
+			w.w.WriteByte(':')
+			w.w.WriteString(decl.prefix)
+		}
+		w.w.WriteString(`="`)
+		escapeAttr(w.w, decl.uri)
+		w.w.WriteByte('"')
+	}
+	for i, attr := range start.Attr {
+		if w.attrs[i] == "" {
+			continue
+		}
+		w.w.WriteByte(' ')
+		w.w.WriteString(w.attrs[i])
+		w.w.WriteString(`="`)
+		escapeAttr(w.w, attr.Value)
+		w.w.WriteByte('"')
+	}
+}
+
+// writeAttrLayout is like writeAttrs except that it starts new lines between
+// attributes as configured by the WrapAttr and LineWidth options.
+func (w *xmlWriter) writeAttrLayout(qname string, start xml.StartElement) {
+	lead := string(w.lead)
+	w.advance("<"+qname, nil)
+	// Continuation lines are aligned with the first attribute.
+	align := w.col + 1
+
+	w.out = w.out[:0]
+	add := func(name, value string) {
+		w.out = append(w.out, xmlAttrOut{
+			name:  name,
+			value: value,
+			width: len(` =""`) + textWidth(name, nil) + textWidth(value, &attrSpecial),
+		})
+	}
+	for _, decl := range w.decls {
+		name := "xmlns"
+		if decl.prefix != "" {
+			name += ":" + decl.prefix
+		}
+		add(name, decl.uri)
+	}
+	for i, attr := range start.Attr {
+		if w.attrs[i] != "" {
+			add(w.attrs[i], attr.Value)
+		}
+	}
+
+	// Leave room for the ">" that ends the tag.
+	end := w.col + 1
+	for _, attr := range w.out {
+		end += attr.width
+	}
+	wrap := w.wrap > 0 && end > w.wrap
+
+	for i, attr := range w.out {
+		width := attr.width
+		if i == len(w.out)-1 {
+			width++
+		}
+		if i > 0 && (wrap || w.width > 0 && w.col+width > w.width) {
+			w.w.WriteByte('\n')
+			w.w.WriteString(lead)
+			w.lead = append(w.lead[:0], lead...)
+			for j := len(lead); j < align-1; j++ {
+				w.w.WriteByte(' ')
+				w.lead = append(w.lead, ' ')
+			}
+			w.col = len(w.lead)
+		}
+		w.w.WriteByte(' ')
+		w.w.WriteString(attr.name)
+		w.w.WriteString(`="`)
+		escapeAttr(w.w, attr.value)
+		w.w.WriteByte('"')
+		w.col += attr.width
+	}
+}
+
+// resolve picks the prefixes used to write a start element and its attributes
+// and records the namespace declarations that must be added.
+func (w *xmlWriter) resolve(el *xmlElem, start xml.StartElement) error {
+	// ownDefault is the index of the element's own default namespace
+	// declaration, if any.
+	ownDefault := -1
+	for i, attr := range start.Attr {
+		switch {
+		case attr.Name.Space == "xmlns":
+			w.ns.bind(attr.Name.Local, attr.Value)
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			w.ns.bind("", attr.Value)
+			ownDefault = i
+		}
+	}
+
+	if err := w.checkName(start.Name.Local); err != nil {
+		return err
+	}
+	space := start.Name.Space
+	def, _ := w.ns.uri("")
+	drop := -1
+	switch {
+	case def == space:
+		el.qname = start.Name.Local
+	case space == "":
+		// An element that is not in a namespace cannot have a prefix, so if it
+		// declares a different default namespace the declaration is replaced.
+		drop = ownDefault
+		w.declare("", "")
+		el.qname = start.Name.Local
+	case ownDefault != -1:
+		// The default namespace is declared by the element itself, so declaring it
+		// again would result in a duplicate attribute.
+		prefix, ok := w.ns.prefix(space)
+		if !ok {
+			prefix = w.genPrefix()
+			w.declare(prefix, space)
+		}
+		el.qname = prefix + ":" + start.Name.Local
+	default:
+		if prefix, ok := w.ns.prefix(space); ok {
+			el.qname = prefix + ":" + start.Name.Local
+		} else {
+			w.declare("", space)
+			el.qname = start.Name.Local
+		}
+	}
+
+	for i, attr := range start.Attr {
+		if i == drop {
+			w.attrs = append(w.attrs, "")
+			continue
+		}
+		if err := w.checkName(attr.Name.Local); err != nil {
+			return err
+		}
+		switch attr.Name.Space {
+		case "":
+			w.attrs = append(w.attrs, attr.Name.Local)
+			continue
+		case "xmlns":
+			w.attrs = append(w.attrs, "xmlns:"+attr.Name.Local)
+			continue
+		case xmlNS:
+			w.attrs = append(w.attrs, "xml:"+attr.Name.Local)
+			continue
+		}
+		prefix, ok := w.ns.prefix(attr.Name.Space)
+		if !ok {
+			prefix = w.genPrefix()
+			w.declare(prefix, attr.Name.Space)
+		}
+		w.attrs = append(w.attrs, prefix+":"+attr.Name.Local)
+	}
+	return nil
+}
+
+// genPrefix returns a prefix that is not yet bound.
+func (w *xmlWriter) genPrefix() string {
+	for {
+		w.gen++
+		prefix := "ns" + strconv.Itoa(w.gen)
+		if _, bound := w.ns.uri(prefix); !bound {
+			return prefix
+		}
+	}
+}
+
+func (w *xmlWriter) writeEnd(end xml.EndElement) error {
+	if len(w.stack) == 0 {
+		if w.noValidate {
+			return nil
+		}
+		return fmt.Errorf("xmlstream: unexpected end element </%s>", end.Name.Local)
+	}
+	el := w.stack[len(w.stack)-1]
+	if !w.noValidate && end.Name != el.name {
+		return fmt.Errorf("xmlstream: end element </%s> does not match start element <%s>", end.Name.Local, el.name.Local)
+	}
+	w.stack = w.stack[:len(w.stack)-1]
+	w.ns.reset(el.bindings)
+	if w.open {
+		w.w.WriteString("/>")
+		w.col += len("/>")
+		w.open = false
+		return nil
+	}
+	w.w.WriteString("</")
+	w.w.WriteString(el.qname)
+	w.w.WriteByte('>')
+	if w.track {
+		w.advance("</"+el.qname+">", nil)
+	}
+	return nil
+}
+
+func (w *xmlWriter) Flush() error {
+	w.closeStart()
+	return w.w.Flush()
+}
+
+func (w *xmlWriter) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if !w.noValidate && len(w.stack) > 0 {
+		return errUnclosed
+	}
+	return nil
+}
+
+// textSpecial and attrSpecial mark the bytes that may need to be escaped in
+// text and attribute values.
+var textSpecial, attrSpecial [256]bool
+
+func init() {
+	for c := 0; c < 256; c++ {
+		special := c < 0x20 && c != '\n' && c != '\t' ||
+			c >= utf8.RuneSelf || c == '&' || c == '<' || c == '>' || c == '\r'
+		textSpecial[c] = special
+		attrSpecial[c] = special || c == '"' || c == '\n' || c == '\t'
+	}
+}
+
+// escapeAttr writes an attribute value to w, avoiding a copy if it does not
+// need to be escaped.
+func escapeAttr(w *bufio.Writer, s string) {
+	for i := 0; i < len(s); i++ {
+		if attrSpecial[s[i]] {
+			escapeText(w, []byte(s), true)
+			return
+		}
+	}
+	w.WriteString(s)
+}
+
+// escapeText writes s to w with the characters that are not allowed in text or,
+// if attr is true, in attribute values escaped.
+// Characters that are not allowed in XML are replaced with U+FFFD.
+func escapeText(w *bufio.Writer, s []byte, attr bool) {
+	special := &textSpecial
+	if attr {
+		special = &attrSpecial
+	}
+	last := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if !special[c] {
+			i++
+			continue
+		}
+		var esc string
+		width := 1
+		switch c {
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		case '\r':
+			esc = "&#xD;"
+		case '"':
+			esc = "&#34;"
+		case '\n':
+			esc = "&#xA;"
+		case '\t':
+			esc = "&#x9;"
+		default:
+			if c < utf8.RuneSelf {
+				esc = "\uFFFD"
+				break
+			}
+			var r rune
+			r, width = utf8.DecodeRune(s[i:])
+			if (r == utf8.RuneError && width == 1) || r == '\uFFFE' || r == '\uFFFF' {
+				esc = "\uFFFD"
+			}
+		}
+		if esc != "" {
+			w.Write(s[last:i])
+			w.WriteString(esc)
+			last = i + width
+		}
+		i += width
+	}
+	w.Write(s[last:])
+}
@@ -0,0 +1,316 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+var xmlWriterTestCases = [...]struct {
+	in   string
+	out  string
+	raw  bool
+	opts []xmlstream.XMLWriterOption
+	err  bool
+}{
+	0: {},
+	1: {
+		in:  `<a b="c">text &amp; &lt;more&gt;</a>`,
+		out: `<a b="c">text &amp; &lt;more&gt;</a>`,
+	},
+	2: {
+		in:  `<message xmlns="jabber:client"><body>hi</body></message>`,
+		out: `<message xmlns="jabber:client"><body>hi</body></message>`,
+	},
+	3: {
+		in:  `<stream:stream xmlns="jabber:client" xmlns:stream="http://etherx.jabber.org/streams"><stream:features/><message/></stream:stream>`,
+		out: `<stream:stream xmlns="jabber:client" xmlns:stream="http://etherx.jabber.org/streams"><stream:features></stream:features><message></message></stream:stream>`,
+	},
+	4: {
+		in:  `<a xmlns="urn:a"><b xmlns="urn:b"><c xmlns=""/></b></a>`,
+		out: `<a xmlns="urn:a"><b xmlns="urn:b"><c xmlns=""></c></b></a>`,
+	},
+	5: {
+		in:  `<a xmlns:p="urn:p" p:b="c" xml:lang="en"/>`,
+		out: `<a xmlns:p="urn:p" p:b="c" xml:lang="en"></a>`,
+	},
+	6: {
+		in:   `<a xmlns:p="urn:p"><p:b p:c="d"/></a>`,
+		out:  `<a xmlns:p="urn:p"><p:b p:c="d"/></a>`,
+		raw:  true,
+		opts: []xmlstream.XMLWriterOption{xmlstream.RawNames(), xmlstream.SelfClose()},
+	},
+	7: {
+		in:   `<a><b/><c>x</c></a>`,
+		out:  `<a><b/><c>x</c></a>`,
+		opts: []xmlstream.XMLWriterOption{xmlstream.SelfClose()},
+	},
+	8: {
+		in:  `<?xml version="1.0"?><!-- c --><a attr="&#34;&#xA;&#x9;"/>`,
+		out: `<?xml version="1.0"?><!-- c --><a attr="&#34;&#xA;&#x9;"></a>`,
+	},
+	9: {
+		in:  `<a>`,
+		out: `<a>`,
+		err: true,
+	},
+	10: {
+		in:   `<a><iq type="get" id="1" to="a@b"/></a>`,
+		out:  "<a><iq type=\"get\"\n       id=\"1\"\n       to=\"a@b\"></iq></a>",
+		opts: []xmlstream.XMLWriterOption{xmlstream.WrapAttr(20)},
+	},
+	11: {
+		in:   `<a><iq type="get" id="1" to="a@b"/></a>`,
+		out:  `<a><iq type="get" id="1" to="a@b"></iq></a>`,
+		opts: []xmlstream.XMLWriterOption{xmlstream.WrapAttr(40)},
+	},
+	12: {
+		in:   `<iq type="get" id="1" to="a@b"/>`,
+		out:  "<iq type=\"get\"\n    id=\"1\" to=\"a@b\"></iq>",
+		opts: []xmlstream.XMLWriterOption{xmlstream.LineWidth(20)},
+	},
+	13: {
+		in:   "<a>\n\t<b x=\"1\" y=\"2\"/>\n</a>",
+		out:  "<a>\n\t<b x=\"1\"\n\t   y=\"2\"/>\n</a>",
+		opts: []xmlstream.XMLWriterOption{xmlstream.WrapAttr(10), xmlstream.SelfClose()},
+	},
+	14: {
+		in:   `<a xmlns="urn:a" b="&amp;&amp;"/>`,
+		out:  "<a xmlns=\"urn:a\"\n   b=\"&amp;&amp;\"></a>",
+		opts: []xmlstream.XMLWriterOption{xmlstream.LineWidth(22)},
+	},
+}
+
+func TestXMLWriter(t *testing.T) {
+	for i, tc := range xmlWriterTestCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var buf strings.Builder
+			w := xmlstream.XMLWriter(&buf, tc.opts...)
+			var r xml.TokenReader = xml.NewDecoder(strings.NewReader(tc.in))
+			if tc.raw {
+				r = rawReader{xml.NewDecoder(strings.NewReader(tc.in))}
+			}
+			_, err := xmlstream.Copy(w, r)
+			if err != nil && !tc.err {
+				t.Fatalf("error encoding: %v", err)
+			}
+			err = w.Close()
+			switch {
+			case tc.err && err == nil:
+				t.Errorf("expected error closing writer")
+			case !tc.err && err != nil:
+				t.Errorf("error closing writer: %v", err)
+			}
+			if out := buf.String(); out != tc.out {
+				t.Errorf("wrong output:\nwant=%s,\n got=%s", tc.out, out)
+			}
+		})
+	}
+}
+
+type rawReader struct {
+	d *xml.Decoder
+}
+
+func (r rawReader) Token() (xml.Token, error) {
+	return r.d.RawToken()
+}
+
+func TestXMLWriterNamespaces(t *testing.T) {
+	var buf strings.Builder
+	w := xmlstream.XMLWriter(&buf)
+	toks := []xml.Token{
+		xml.StartElement{Name: xml.Name{Space: "urn:a", Local: "a"}, Attr: []xml.Attr{
+			{Name: xml.Name{Space: "urn:x", Local: "x"}, Value: "1"},
+		}},
+		xml.StartElement{Name: xml.Name{Space: "urn:x", Local: "b"}},
+		xml.StartElement{Name: xml.Name{Local: "c"}, Attr: []xml.Attr{
+			{Name: xml.Name{Space: "urn:y", Local: "y"}, Value: "2"},
+		}},
+		xml.EndElement{Name: xml.Name{Local: "c"}},
+		xml.EndElement{Name: xml.Name{Space: "urn:x", Local: "b"}},
+		xml.EndElement{Name: xml.Name{Space: "urn:a", Local: "a"}},
+	}
+	for _, tok := range toks {
+		if err := w.EncodeToken(tok); err != nil {
+			t.Fatalf("error encoding %#v: %v", tok, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("error closing: %v", err)
+	}
+	const want = `<a xmlns="urn:a" xmlns:ns1="urn:x" ns1:x="1"><ns1:b><c xmlns="" xmlns:ns2="urn:y" ns2:y="2"></c></ns1:b></a>`
+	if out := buf.String(); out != want {
+		t.Errorf("wrong output:\nwant=%s,\n got=%s", want, out)
+	}
+}
+
+var xmlWriterConflictTestCases = [...]struct {
+	toks []xml.Token
+	out  string
+}{
+	0: {
+		toks: []xml.Token{
+			xml.StartElement{Name: xml.Name{Local: "x"}, Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: "urn:a"}}},
+			xml.EndElement{Name: xml.Name{Local: "x"}},
+		},
+		out: `<x xmlns=""></x>`,
+	},
+	1: {
+		toks: []xml.Token{
+			xml.StartElement{Name: xml.Name{Space: "urn:b", Local: "x"}, Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: "urn:a"}}},
+			xml.EndElement{Name: xml.Name{Space: "urn:b", Local: "x"}},
+		},
+		out: `<ns1:x xmlns:ns1="urn:b" xmlns="urn:a"></ns1:x>`,
+	},
+	2: {
+		toks: []xml.Token{
+			xml.StartElement{Name: xml.Name{Space: "urn:b", Local: "x"}, Attr: []xml.Attr{
+				{Name: xml.Name{Space: "xmlns", Local: "b"}, Value: "urn:b"},
+				{Name: xml.Name{Local: "xmlns"}, Value: "urn:a"},
+			}},
+			xml.StartElement{Name: xml.Name{Space: "urn:a", Local: "y"}},
+			xml.EndElement{Name: xml.Name{Space: "urn:a", Local: "y"}},
+			xml.EndElement{Name: xml.Name{Space: "urn:b", Local: "x"}},
+		},
+		out: `<b:x xmlns:b="urn:b" xmlns="urn:a"><y></y></b:x>`,
+	},
+}
+
+func TestXMLWriterDefaultConflict(t *testing.T) {
+	for i, tc := range xmlWriterConflictTestCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var buf strings.Builder
+			w := xmlstream.XMLWriter(&buf)
+			for _, tok := range tc.toks {
+				if err := w.EncodeToken(tok); err != nil {
+					t.Fatalf("error encoding %#v: %v", tok, err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("error closing: %v", err)
+			}
+			if out := buf.String(); out != tc.out {
+				t.Errorf("wrong output:\nwant=%s,\n got=%s", tc.out, out)
+			}
+		})
+	}
+}
+
+var xmlWriterErrTestCases = [...]struct {
+	tok  xml.Token
+	opts []xmlstream.XMLWriterOption
+	err  bool
+}{
+	0: {tok: xml.StartElement{Name: xml.Name{Local: "1a"}}, err: true},
+	1: {tok: xml.StartElement{Name: xml.Name{Local: "a b"}}, err: true},
+	2: {tok: xml.StartElement{Name: xml.Name{Local: "a"}, Attr: []xml.Attr{{Name: xml.Name{Local: "<"}}}}, err: true},
+	3: {tok: xml.EndElement{Name: xml.Name{Local: "a"}}, err: true},
+	4: {tok: xml.Comment("a--b"), err: true},
+	5: {tok: xml.ProcInst{Target: "x", Inst: []byte("?>")}, err: true},
+	6: {tok: xml.StartElement{Name: xml.Name{Local: "1a"}}, opts: []xmlstream.XMLWriterOption{xmlstream.NoValidate()}},
+	7: {tok: xml.EndElement{Name: xml.Name{Local: "a"}}, opts: []xmlstream.XMLWriterOption{xmlstream.NoValidate()}},
+	8: {tok: xml.StartElement{Name: xml.Name{Local: "été-1.a"}}},
+}
+
+func TestXMLWriterErrors(t *testing.T) {
+	for i, tc := range xmlWriterErrTestCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			w := xmlstream.XMLWriter(io.Discard, tc.opts...)
+			err := w.EncodeToken(tc.tok)
+			switch {
+			case tc.err && err == nil:
+				t.Errorf("expected error encoding %#v", tc.tok)
+			case !tc.err && err != nil:
+				t.Errorf("unexpected error encoding %#v: %v", tc.tok, err)
+			}
+		})
+	}
+}
+
+func TestXMLWriterMismatch(t *testing.T) {
+	w := xmlstream.XMLWriter(io.Discard)
+	if err := w.EncodeToken(xml.StartElement{Name: xml.Name{Local: "a"}}); err != nil {
+		t.Fatalf("error encoding start: %v", err)
+	}
+	if err := w.EncodeToken(xml.EndElement{Name: xml.Name{Local: "b"}}); err == nil {
+		t.Errorf("expected error for mismatched end element")
+	}
+}
+
+func TestXMLWriterEscape(t *testing.T) {
+	var buf strings.Builder
+	w := xmlstream.XMLWriter(&buf)
+	start := xml.StartElement{Name: xml.Name{Local: "a"}, Attr: []xml.Attr{{Name: xml.Name{Local: "b"}, Value: "<\"é\">"}}}
+	for _, tok := range []xml.Token{start, xml.CharData("\x00\r\n\t\xff\"é"), start.End()} {
+		if err := w.EncodeToken(tok); err != nil {
+			t.Fatalf("error encoding: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("error closing: %v", err)
+	}
+	const want = "<a b=\"&lt;&#34;é&#34;&gt;\">�&#xD;\n\t�\"é</a>"
+	if out := buf.String(); out != want {
+		t.Errorf("wrong output:\nwant=%q,\n got=%q", want, out)
+	}
+}
+
+func BenchmarkXMLWriterCopy(b *testing.B) {
+	benchmarkWriterCopy(b, func(w io.Writer) xmlstream.TokenWriteFlusher {
+		return xmlstream.XMLWriter(w)
+	})
+}
+
+func BenchmarkXMLWriterCopyNoValidate(b *testing.B) {
+	benchmarkWriterCopy(b, func(w io.Writer) xmlstream.TokenWriteFlusher {
+		return xmlstream.XMLWriter(w, xmlstream.NoValidate())
+	})
+}
+
+func BenchmarkXMLEncoderCopy(b *testing.B) {
+	benchmarkWriterCopy(b, func(w io.Writer) xmlstream.TokenWriteFlusher {
+		return xml.NewEncoder(w)
+	})
+}
+
+func benchmarkWriterCopy(b *testing.B, f func(io.Writer) xmlstream.TokenWriteFlusher) {
+	toks, err := xmlstream.ReadAll(xml.NewDecoder(strings.NewReader(benchmarkStream())))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		w := f(&buf)
+		r := tokenSlice(toks)
+		if _, err := xmlstream.Copy(w, &r); err != nil {
+			b.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type tokenSlice []xml.Token
+
+func (s *tokenSlice) Token() (xml.Token, error) {
+	if len(*s) == 0 {
+		return nil, io.EOF
+	}
+	t := (*s)[0]
+	*s = (*s)[1:]
+	return t, nil
+}
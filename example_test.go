@@ -247,3 +247,34 @@ func ExampleLimitReader() {
 	// Output:
 	// <one>One hen</one>
 }
+
+func ExampleScopeReader() {
+	r := xmlstream.NewScopeReader(xml.NewDecoder(strings.NewReader(`<message xml:lang="en">
+  <body>Hello</body>
+  <body xml:lang="de">Hallo</body>
+  <body xml:lang="fr">Bonjour</body>
+</message>`)), nil)
+
+	// Read the <message> start element and then iterate over its children.
+	if _, err := r.Token(); err != nil {
+		log.Fatal("Error reading message:", err)
+	}
+	iter := xmlstream.NewIter(r)
+	for iter.Next() {
+		start, body := iter.Current()
+		if start == nil || r.Lang() != "de" {
+			continue
+		}
+		toks, err := xmlstream.ReadAll(body)
+		if err != nil {
+			log.Fatal("Error reading body:", err)
+		}
+		fmt.Printf("%s\n", toks[0])
+	}
+	if err := iter.Err(); err != nil {
+		log.Fatal("Error iterating:", err)
+	}
+
+	// Output:
+	// Hallo
+}
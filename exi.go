@@ -0,0 +1,781 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math/bits"
+	"unicode/utf8"
+)
+
+// This file implements the schema-less mode of Efficient XML Interchange (EXI)
+// Format 1.0 with the default options: bit-packed alignment, strict mode off,
+// and comments, processing instructions, DTDs, and prefixes not preserved.
+
+const xsiNS = "http://www.w3.org/2001/XMLSchema-instance"
+
+var (
+	errEXIHeader    = errors.New("xmlstream: unsupported EXI header")
+	errEXIEvent     = errors.New("xmlstream: invalid EXI event")
+	errEXIRoot      = errors.New("xmlstream: EXI documents must have exactly one root element")
+	errEXIUnclosed  = errors.New("xmlstream: EXI document closed before the root element ended")
+	errEXIMalformed = errors.New("xmlstream: malformed EXI stream")
+)
+
+// exiKind is the kind of an EXI event.
+type exiKind uint8
+
+const (
+	exiSE exiKind = iota
+	exiAT
+	exiCH
+	exiEE
+)
+
+// exiQName is a qualified name as indices into the URI and local name string
+// table partitions.
+type exiQName struct {
+	uri, local int
+}
+
+// exiProd is a learned production in a built-in element grammar.
+type exiProd struct {
+	kind exiKind
+	name exiQName
+}
+
+// exiGrammar is a built-in element grammar, which is made up of the
+// StartTagContent and ElementContent non-terminals.
+// Learned productions are stored with the most recently learned first, which
+// is also their event code.
+type exiGrammar struct {
+	start   []exiProd
+	content []exiProd
+}
+
+func learn(prods *[]exiProd, p exiProd) {
+	*prods = append(*prods, exiProd{})
+	copy((*prods)[1:], *prods)
+	(*prods)[0] = p
+}
+
+// exiTables holds the string tables and grammars shared by the encoder and
+// decoder.
+type exiTables struct {
+	uris     []string
+	uriIDs   map[string]int
+	locals   [][]string
+	localIDs []map[string]int
+	global   []string
+	globalID map[string]int
+	values   map[exiQName][]string
+	valueIDs map[exiQName]map[string]int
+	grammars map[exiQName]*exiGrammar
+}
+
+func newEXITables() *exiTables {
+	t := &exiTables{
+		uriIDs:   make(map[string]int),
+		globalID: make(map[string]int),
+		values:   make(map[exiQName][]string),
+		valueIDs: make(map[exiQName]map[string]int),
+		grammars: make(map[exiQName]*exiGrammar),
+	}
+	t.addURI("")
+	t.addURI(xmlNS)
+	t.addURI(xsiNS)
+	for _, l := range []string{"base", "id", "lang", "space"} {
+		t.addLocal(1, l)
+	}
+	for _, l := range []string{"nil", "type"} {
+		t.addLocal(2, l)
+	}
+	return t
+}
+
+func (t *exiTables) addURI(uri string) int {
+	id := len(t.uris)
+	t.uris = append(t.uris, uri)
+	t.uriIDs[uri] = id
+	t.locals = append(t.locals, nil)
+	t.localIDs = append(t.localIDs, make(map[string]int))
+	return id
+}
+
+func (t *exiTables) addLocal(uri int, local string) int {
+	id := len(t.locals[uri])
+	t.locals[uri] = append(t.locals[uri], local)
+	t.localIDs[uri][local] = id
+	return id
+}
+
+func (t *exiTables) addValue(name exiQName, v string) {
+	if v == "" {
+		return
+	}
+	t.globalID[v] = len(t.global)
+	t.global = append(t.global, v)
+	ids := t.valueIDs[name]
+	if ids == nil {
+		ids = make(map[string]int)
+		t.valueIDs[name] = ids
+	}
+	ids[v] = len(t.values[name])
+	t.values[name] = append(t.values[name], v)
+}
+
+func (t *exiTables) grammar(name exiQName) *exiGrammar {
+	g, ok := t.grammars[name]
+	if !ok {
+		g = &exiGrammar{}
+		t.grammars[name] = g
+	}
+	return g
+}
+
+func (t *exiTables) name(q exiQName) xml.Name {
+	return xml.Name{Space: t.uris[q.uri], Local: t.locals[q.uri][q.local]}
+}
+
+// width returns the number of bits needed to represent n distinct values.
+func width(n int) int {
+	if n <= 1 {
+		return 0
+	}
+	return bits.Len(uint(n - 1))
+}
+
+// exiElem is an element that has been started but not ended.
+type exiElem struct {
+	name    exiQName
+	g       *exiGrammar
+	content bool
+}
+
+// EXIWriter returns a TokenWriter that encodes a single XML document to w using
+// the schema-less mode of Efficient XML Interchange (EXI) with the default
+// options.
+//
+// Comments, processing instructions, directives, and namespace declarations
+// are not preserved, and xsi:type and xsi:nil attributes are treated like any
+// other attribute, so their values are encoded as strings.
+// EXI encodes the value of xsi:type as a QName, so documents that use it may
+// not be decoded correctly by other implementations.
+// The output has only been tested against EXIReader and not against other EXI
+// implementations.
+// The document must have exactly one root element.
+// Flush writes all complete bytes to w, and Close finishes the document and
+// flushes it but does not close w.
+func EXIWriter(w io.Writer) TokenWriteFlushCloser {
+	return &exiWriter{
+		w: bufio.NewWriter(w),
+		t: newEXITables(),
+	}
+}
+
+type exiWriter struct {
+	w       *bufio.Writer
+	t       *exiTables
+	acc     uint64
+	n       int
+	stack   []*exiElem
+	started bool
+	done    bool
+	err     error
+}
+
+// bits writes the n low bits of v, most significant bit first.
+func (e *exiWriter) bits(v uint64, n int) {
+	for n > 0 {
+		take := 8 - e.n
+		if take > n {
+			take = n
+		}
+		n -= take
+		e.acc = e.acc<<take | (v>>n)&(1<<take-1)
+		e.n += take
+		if e.n == 8 {
+			if e.err == nil {
+				e.err = e.w.WriteByte(byte(e.acc))
+			}
+			e.acc, e.n = 0, 0
+		}
+	}
+}
+
+func (e *exiWriter) uint(v uint64) {
+	for {
+		b := v & 0x7f
+		v >>= 7
+		if v != 0 {
+			b |= 0x80
+		}
+		e.bits(b, 8)
+		if v == 0 {
+			return
+		}
+	}
+}
+
+func (e *exiWriter) chars(s string) {
+	for _, r := range s {
+		e.uint(uint64(r))
+	}
+}
+
+func (e *exiWriter) qname(n xml.Name) exiQName {
+	t := e.t
+	var q exiQName
+	uri, ok := t.uriIDs[n.Space]
+	if ok {
+		e.bits(uint64(uri+1), width(len(t.uris)+1))
+	} else {
+		e.bits(0, width(len(t.uris)+1))
+		e.uint(uint64(utf8.RuneCountInString(n.Space)))
+		e.chars(n.Space)
+		uri = t.addURI(n.Space)
+	}
+	q.uri = uri
+
+	local, ok := t.localIDs[uri][n.Local]
+	if ok {
+		e.uint(0)
+		e.bits(uint64(local), width(len(t.locals[uri])))
+	} else {
+		e.uint(uint64(utf8.RuneCountInString(n.Local)) + 1)
+		e.chars(n.Local)
+		local = t.addLocal(uri, n.Local)
+	}
+	q.local = local
+	return q
+}
+
+// lookup returns the qualified name for n if it is already in the string
+// tables.
+func (e *exiWriter) lookup(n xml.Name) (exiQName, bool) {
+	uri, ok := e.t.uriIDs[n.Space]
+	if !ok {
+		return exiQName{}, false
+	}
+	local, ok := e.t.localIDs[uri][n.Local]
+	return exiQName{uri: uri, local: local}, ok
+}
+
+func (e *exiWriter) value(name exiQName, v string) {
+	t := e.t
+	if id, ok := t.valueIDs[name][v]; ok {
+		e.uint(0)
+		e.bits(uint64(id), width(len(t.values[name])))
+		return
+	}
+	if id, ok := t.globalID[v]; ok {
+		e.uint(1)
+		e.bits(uint64(id), width(len(t.global)))
+		return
+	}
+	e.uint(uint64(utf8.RuneCountInString(v)) + 2)
+	e.chars(v)
+	t.addValue(name, v)
+}
+
+// event writes the event code for an event in the current element's grammar.
+// It reports whether the event matched a learned production, in which case
+// the qualified name is not written.
+func (e *exiWriter) event(kind exiKind, name exiQName, known bool) bool {
+	el := e.stack[len(e.stack)-1]
+	g := el.g
+	prods := &g.start
+	if el.content {
+		prods = &g.content
+	}
+	for i, p := range *prods {
+		named := kind == exiSE || kind == exiAT
+		if p.kind == kind && (!named || known && p.name == name) {
+			if el.content {
+				e.bits(uint64(i), width(len(*prods)+2))
+			} else {
+				e.bits(uint64(i), width(len(*prods)+1))
+			}
+			return true
+		}
+	}
+
+	k := len(*prods)
+	if el.content {
+		if kind == exiEE {
+			e.bits(uint64(k), width(k+2))
+			return true
+		}
+		e.bits(uint64(k+1), width(k+2))
+		switch kind {
+		case exiSE:
+			e.bits(0, 1)
+		case exiCH:
+			e.bits(1, 1)
+		}
+	} else {
+		e.bits(uint64(k), width(k+1))
+		e.bits(startCodes[kind], 2)
+	}
+	return false
+}
+
+// startCodes are the second level event codes of the undeclared productions in
+// StartTagContent.
+var startCodes = [...]uint64{exiEE: 0, exiAT: 1, exiSE: 2, exiCH: 3}
+
+// learnEvent adds a production for an event that did not match a learned
+// production.
+func (e *exiWriter) learnEvent(kind exiKind, name exiQName) {
+	el := e.stack[len(e.stack)-1]
+	if el.content {
+		if kind != exiEE {
+			learn(&el.g.content, exiProd{kind: kind, name: name})
+		}
+		return
+	}
+	learn(&el.g.start, exiProd{kind: kind, name: name})
+}
+
+func (e *exiWriter) startElement(start xml.StartElement) {
+	if len(e.stack) == 0 {
+		if e.done {
+			e.err = errEXIRoot
+			return
+		}
+		// Header: distinguishing bits, no options, final version 1.
+		e.bits(0x80, 8)
+		e.started = true
+		// The SD and SE(*) events in the document grammar have zero width codes.
+		q := e.qname(start.Name)
+		e.stack = append(e.stack, &exiElem{name: q, g: e.t.grammar(q)})
+	} else {
+		q, known := e.lookup(start.Name)
+		if !e.event(exiSE, q, known) {
+			q = e.qname(start.Name)
+			e.learnEvent(exiSE, q)
+		}
+		e.stack[len(e.stack)-1].content = true
+		e.stack = append(e.stack, &exiElem{name: q, g: e.t.grammar(q)})
+	}
+
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			continue
+		}
+		q, known := e.lookup(attr.Name)
+		if !e.event(exiAT, q, known) {
+			q = e.qname(attr.Name)
+			e.learnEvent(exiAT, q)
+		}
+		e.value(q, attr.Value)
+	}
+}
+
+func (e *exiWriter) EncodeToken(t xml.Token) error {
+	if e.err != nil {
+		return e.err
+	}
+	switch tok := t.(type) {
+	case xml.StartElement:
+		e.startElement(tok)
+	case xml.EndElement:
+		if len(e.stack) == 0 {
+			return errEXIEvent
+		}
+		if !e.event(exiEE, exiQName{}, true) {
+			e.learnEvent(exiEE, exiQName{})
+		}
+		e.stack = e.stack[:len(e.stack)-1]
+		if len(e.stack) > 0 {
+			e.stack[len(e.stack)-1].content = true
+		} else {
+			// The ED event in the document grammar has a zero width code.
+			e.done = true
+		}
+	case xml.CharData:
+		if len(tok) == 0 {
+			break
+		}
+		if len(e.stack) == 0 {
+			if isWhitespace(tok) {
+				break
+			}
+			return errEXIRoot
+		}
+		if !e.event(exiCH, exiQName{}, true) {
+			e.learnEvent(exiCH, exiQName{})
+		}
+		el := e.stack[len(e.stack)-1]
+		e.value(el.name, string(tok))
+		el.content = true
+	}
+	return e.err
+}
+
+func (e *exiWriter) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+func (e *exiWriter) Close() error {
+	switch {
+	case e.err != nil:
+		return e.err
+	case !e.started:
+		return errEXIRoot
+	case !e.done:
+		return errEXIUnclosed
+	}
+	if e.n > 0 {
+		e.bits(0, 8-e.n)
+	}
+	return e.Flush()
+}
+
+// EXIReader returns a reader that decodes an XML document encoded using the
+// schema-less mode of Efficient XML Interchange (EXI) with the default options,
+// such as one written by EXIWriter.
+// The optional "$EXI" cookie is accepted, but streams that include EXI options
+// in their header are not supported.
+// Names in the returned tokens have the namespace set in their Space field, but
+// no namespace declarations are included.
+func EXIReader(r io.Reader) xml.TokenReader {
+	return &exiReader{
+		r: bufio.NewReader(r),
+		t: newEXITables(),
+	}
+}
+
+type exiReader struct {
+	r      *bufio.Reader
+	t      *exiTables
+	acc    byte
+	n      int
+	stack  []*exiElem
+	queue  []xml.Token
+	header bool
+	err    error
+}
+
+func (d *exiReader) bits(n int) (uint64, error) {
+	var v uint64
+	for n > 0 {
+		if d.n == 0 {
+			b, err := d.r.ReadByte()
+			if err != nil {
+				return 0, unexpectedEOF(err)
+			}
+			d.acc, d.n = b, 8
+		}
+		take := d.n
+		if take > n {
+			take = n
+		}
+		v = v<<take | uint64(d.acc>>(d.n-take))&(1<<take-1)
+		d.n -= take
+		n -= take
+	}
+	return v, nil
+}
+
+func (d *exiReader) uint() (uint64, error) {
+	var v uint64
+	for shift := 0; ; shift += 7 {
+		if shift > 63 {
+			return 0, errEXIMalformed
+		}
+		b, err := d.bits(8)
+		if err != nil {
+			return 0, err
+		}
+		v |= (b & 0x7f) << shift
+		if b&0x80 == 0 {
+			return v, nil
+		}
+	}
+}
+
+func (d *exiReader) chars(n uint64) (string, error) {
+	// The length comes from the stream, so only allocate space for a few
+	// characters up front and let the buffer grow as characters are read.
+	size := n
+	if size > 64 {
+		size = 64
+	}
+	buf := make([]byte, 0, size)
+	for i := uint64(0); i < n; i++ {
+		c, err := d.uint()
+		if err != nil {
+			return "", err
+		}
+		if c > utf8.MaxRune {
+			return "", errEXIMalformed
+		}
+		buf = utf8.AppendRune(buf, rune(c))
+	}
+	return string(buf), nil
+}
+
+func (d *exiReader) qname() (exiQName, error) {
+	t := d.t
+	var q exiQName
+	v, err := d.bits(width(len(t.uris) + 1))
+	if err != nil {
+		return q, err
+	}
+	if v == 0 {
+		l, err := d.uint()
+		if err != nil {
+			return q, err
+		}
+		uri, err := d.chars(l)
+		if err != nil {
+			return q, err
+		}
+		q.uri = t.addURI(uri)
+	} else {
+		if v > uint64(len(t.uris)) {
+			return q, errEXIMalformed
+		}
+		q.uri = int(v - 1)
+	}
+
+	l, err := d.uint()
+	if err != nil {
+		return q, err
+	}
+	if l == 0 {
+		id, err := d.bits(width(len(t.locals[q.uri])))
+		if err != nil {
+			return q, err
+		}
+		if id >= uint64(len(t.locals[q.uri])) {
+			return q, errEXIMalformed
+		}
+		q.local = int(id)
+		return q, nil
+	}
+	local, err := d.chars(l - 1)
+	if err != nil {
+		return q, err
+	}
+	q.local = t.addLocal(q.uri, local)
+	return q, nil
+}
+
+func (d *exiReader) value(name exiQName) (string, error) {
+	t := d.t
+	v, err := d.uint()
+	if err != nil {
+		return "", err
+	}
+	switch v {
+	case 0:
+		id, err := d.bits(width(len(t.values[name])))
+		if err != nil {
+			return "", err
+		}
+		if id >= uint64(len(t.values[name])) {
+			return "", errEXIMalformed
+		}
+		return t.values[name][id], nil
+	case 1:
+		id, err := d.bits(width(len(t.global)))
+		if err != nil {
+			return "", err
+		}
+		if id >= uint64(len(t.global)) {
+			return "", errEXIMalformed
+		}
+		return t.global[id], nil
+	}
+	s, err := d.chars(v - 2)
+	if err != nil {
+		return "", err
+	}
+	t.addValue(name, s)
+	return s, nil
+}
+
+func (d *exiReader) readHeader() error {
+	b, err := d.r.Peek(1)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if b[0] == '$' {
+		cookie := make([]byte, 4)
+		if _, err := io.ReadFull(d.r, cookie); err != nil {
+			return unexpectedEOF(err)
+		}
+		if string(cookie) != "$EXI" {
+			return errEXIHeader
+		}
+	}
+	h, err := d.bits(8)
+	if err != nil {
+		return err
+	}
+	if h != 0x80 {
+		return errEXIHeader
+	}
+	return nil
+}
+
+// event reads the next event in the current element's grammar, learning new
+// productions as necessary.
+func (d *exiReader) event() (exiKind, exiQName, error) {
+	el := d.stack[len(d.stack)-1]
+	g := el.g
+	var kind exiKind
+	if el.content {
+		k := len(g.content)
+		c, err := d.bits(width(k + 2))
+		if err != nil {
+			return 0, exiQName{}, err
+		}
+		switch {
+		case c < uint64(k):
+			p := g.content[c]
+			return p.kind, p.name, nil
+		case c == uint64(k):
+			return exiEE, exiQName{}, nil
+		case c > uint64(k+1):
+			return 0, exiQName{}, errEXIEvent
+		}
+		c, err = d.bits(1)
+		if err != nil {
+			return 0, exiQName{}, err
+		}
+		kind = exiSE
+		if c == 1 {
+			kind = exiCH
+		}
+	} else {
+		k := len(g.start)
+		c, err := d.bits(width(k + 1))
+		if err != nil {
+			return 0, exiQName{}, err
+		}
+		switch {
+		case c < uint64(k):
+			p := g.start[c]
+			return p.kind, p.name, nil
+		case c > uint64(k):
+			return 0, exiQName{}, errEXIEvent
+		}
+		c, err = d.bits(2)
+		if err != nil {
+			return 0, exiQName{}, err
+		}
+		kind = [...]exiKind{exiEE, exiAT, exiSE, exiCH}[c]
+	}
+
+	var name exiQName
+	if kind == exiSE || kind == exiAT {
+		var err error
+		name, err = d.qname()
+		if err != nil {
+			return 0, exiQName{}, err
+		}
+	}
+	if el.content {
+		learn(&g.content, exiProd{kind: kind, name: name})
+	} else {
+		learn(&g.start, exiProd{kind: kind, name: name})
+	}
+	return kind, name, nil
+}
+
+func (d *exiReader) Token() (xml.Token, error) {
+	for len(d.queue) == 0 {
+		if d.err != nil {
+			return nil, d.err
+		}
+		if err := d.next(); err != nil {
+			d.err = err
+		}
+	}
+	t := d.queue[0]
+	d.queue = d.queue[1:]
+	return t, nil
+}
+
+// next decodes events until at least one token has been queued.
+func (d *exiReader) next() error {
+	if !d.header {
+		d.header = true
+		if err := d.readHeader(); err != nil {
+			return err
+		}
+		q, err := d.qname()
+		if err != nil {
+			return err
+		}
+		return d.start(q)
+	}
+	if len(d.stack) == 0 {
+		return io.EOF
+	}
+
+	kind, name, err := d.event()
+	if err != nil {
+		return err
+	}
+	if kind == exiAT {
+		return errEXIEvent
+	}
+	return d.handle(d.stack[len(d.stack)-1], kind, name)
+}
+
+// start pushes a new element and reads its attributes.
+func (d *exiReader) start(name exiQName) error {
+	el := &exiElem{name: name, g: d.t.grammar(name)}
+	d.stack = append(d.stack, el)
+	start := xml.StartElement{Name: d.t.name(name), Attr: []xml.Attr{}}
+	for {
+		kind, attrName, err := d.event()
+		if err != nil {
+			return err
+		}
+		if kind != exiAT {
+			d.queue = append(d.queue, start)
+			return d.handle(el, kind, attrName)
+		}
+		v, err := d.value(attrName)
+		if err != nil {
+			return err
+		}
+		start.Attr = append(start.Attr, xml.Attr{Name: d.t.name(attrName), Value: v})
+	}
+}
+
+// handle processes an SE, CH, or EE event in the content of el.
+func (d *exiReader) handle(el *exiElem, kind exiKind, name exiQName) error {
+	switch kind {
+	case exiSE:
+		el.content = true
+		return d.start(name)
+	case exiCH:
+		el.content = true
+		v, err := d.value(el.name)
+		if err != nil {
+			return err
+		}
+		d.queue = append(d.queue, xml.CharData(v))
+	case exiEE:
+		d.stack = d.stack[:len(d.stack)-1]
+		if len(d.stack) > 0 {
+			d.stack[len(d.stack)-1].content = true
+		}
+		d.queue = append(d.queue, xml.EndElement{Name: d.t.name(el.name)})
+	}
+	return nil
+}
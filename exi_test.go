@@ -0,0 +1,197 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+var exiTestCases = [...]struct {
+	in  string
+	out string
+}{
+	0: {
+		in:  `<a/>`,
+		out: `<a></a>`,
+	},
+	1: {
+		in:  `<a b="c" d="">text</a>`,
+		out: `<a b="c" d="">text</a>`,
+	},
+	2: {
+		in:  `<a><b>1</b><b>2</b><b>1</b><c x="1"/><c x="1">1</c></a>`,
+		out: `<a><b>1</b><b>2</b><b>1</b><c x="1"></c><c x="1">1</c></a>`,
+	},
+	3: {
+		in:  `<!-- comment --><?pi test?><a>  <b/>  </a>`,
+		out: `<a>  <b></b>  </a>`,
+	},
+	4: {
+		in:  `<stream xmlns="urn:stream"><message xmlns="jabber:client" xml:lang="en"><body>héllo 🙂</body></message><message xmlns="jabber:client"><body>héllo 🙂</body></message></stream>`,
+		out: `<stream xmlns="urn:stream"><message xmlns="jabber:client" xml:lang="en"><body xmlns="jabber:client">héllo 🙂</body></message><message xmlns="jabber:client"><body xmlns="jabber:client">héllo 🙂</body></message></stream>`,
+	},
+	5: {
+		in:  `<a><a><a/></a>x<a>y</a></a>`,
+		out: `<a><a><a></a></a>x<a>y</a></a>`,
+	},
+}
+
+func TestEXI(t *testing.T) {
+	for i, tc := range exiTestCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var buf bytes.Buffer
+			w := xmlstream.EXIWriter(&buf)
+			_, err := xmlstream.Copy(w, xml.NewDecoder(strings.NewReader(tc.in)))
+			if err != nil {
+				t.Fatalf("error encoding: %v", err)
+			}
+			if err = w.Close(); err != nil {
+				t.Fatalf("error closing: %v", err)
+			}
+
+			var out strings.Builder
+			e := xml.NewEncoder(&out)
+			_, err = xmlstream.Copy(e, xmlstream.EXIReader(&buf))
+			if err != nil {
+				t.Fatalf("error decoding: %v", err)
+			}
+			if err = e.Flush(); err != nil {
+				t.Fatalf("error flushing: %v", err)
+			}
+			if s := out.String(); s != tc.out {
+				t.Errorf("wrong output:\nwant=%s,\n got=%s", tc.out, s)
+			}
+		})
+	}
+}
+
+func TestEXIEncoding(t *testing.T) {
+	var buf bytes.Buffer
+	w := xmlstream.EXIWriter(&buf)
+	start := xml.StartElement{Name: xml.Name{Local: "a"}}
+	if err := w.EncodeToken(start); err != nil {
+		t.Fatalf("error encoding start: %v", err)
+	}
+	if err := w.EncodeToken(start.End()); err != nil {
+		t.Fatalf("error encoding end: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("error closing: %v", err)
+	}
+	// Header, SE(*) with a URI hit on "" and a literal local name, then the
+	// undeclared EE production and padding.
+	want := []byte{0x80, 0x40, 0x98, 0x40}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("wrong encoding: want=%#v, got=%#v", want, buf.Bytes())
+	}
+}
+
+func TestEXICookie(t *testing.T) {
+	r := xmlstream.EXIReader(bytes.NewReader([]byte("$EXI\x80\x40\x98\x40")))
+	tok, err := r.Token()
+	if err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	if start, ok := tok.(xml.StartElement); !ok || start.Name.Local != "a" {
+		t.Errorf("wrong token: %#v", tok)
+	}
+}
+
+func TestEXIErrors(t *testing.T) {
+	t.Run("roots", func(t *testing.T) {
+		w := xmlstream.EXIWriter(io.Discard)
+		_, err := xmlstream.Copy(w, xml.NewDecoder(strings.NewReader(`<a/><b/>`)))
+		if err == nil {
+			t.Errorf("expected error encoding multiple roots")
+		}
+	})
+	t.Run("unclosed", func(t *testing.T) {
+		w := xmlstream.EXIWriter(io.Discard)
+		err := w.EncodeToken(xml.StartElement{Name: xml.Name{Local: "a"}})
+		if err != nil {
+			t.Fatalf("error encoding: %v", err)
+		}
+		if err = w.Close(); err == nil {
+			t.Errorf("expected error closing unfinished document")
+		}
+	})
+	t.Run("empty", func(t *testing.T) {
+		w := xmlstream.EXIWriter(io.Discard)
+		if err := w.Close(); err == nil {
+			t.Errorf("expected error closing empty document")
+		}
+	})
+	t.Run("header", func(t *testing.T) {
+		_, err := xmlstream.EXIReader(bytes.NewReader([]byte{0xa0, 0x40})).Token()
+		if err == nil {
+			t.Errorf("expected error decoding options header")
+		}
+	})
+	t.Run("length", func(t *testing.T) {
+		// Header, SE(*) with a URI miss, and a URI length of 2^63-1 characters.
+		b := []byte{0x80, 0x3f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xdf, 0xc0}
+		_, err := xmlstream.ReadAll(xmlstream.EXIReader(bytes.NewReader(b)))
+		if err == nil {
+			t.Errorf("expected error decoding oversized string")
+		}
+	})
+	t.Run("truncated", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := xmlstream.EXIWriter(&buf)
+		_, err := xmlstream.Copy(w, xml.NewDecoder(strings.NewReader(exiTestCases[4].in)))
+		if err != nil {
+			t.Fatalf("error encoding: %v", err)
+		}
+		if err = w.Close(); err != nil {
+			t.Fatalf("error closing: %v", err)
+		}
+		b := buf.Bytes()
+		for i := 0; i < len(b)-1; i++ {
+			_, err := xmlstream.ReadAll(xmlstream.EXIReader(bytes.NewReader(b[:i])))
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("truncated at %d: want=%v, got=%v", i, io.ErrUnexpectedEOF, err)
+			}
+		}
+	})
+}
+
+func FuzzEXIReader(f *testing.F) {
+	for _, tc := range exiTestCases {
+		var buf bytes.Buffer
+		w := xmlstream.EXIWriter(&buf)
+		_, err := xmlstream.Copy(w, xml.NewDecoder(strings.NewReader(tc.in)))
+		if err != nil {
+			f.Fatalf("error encoding: %v", err)
+		}
+		if err = w.Close(); err != nil {
+			f.Fatalf("error closing: %v", err)
+		}
+		f.Add(buf.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		toks, err := xmlstream.ReadAll(xmlstream.EXIReader(bytes.NewReader(b)))
+		if err != nil {
+			return
+		}
+		// Anything that decodes successfully must encode again.
+		w := xmlstream.EXIWriter(io.Discard)
+		for _, tok := range toks {
+			if err := w.EncodeToken(tok); err != nil {
+				t.Fatalf("error re-encoding %#v: %v", tok, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("error closing re-encoded document: %v", err)
+		}
+	})
+}
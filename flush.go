@@ -0,0 +1,170 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+	"sync"
+	"time"
+)
+
+// A Clock schedules delayed function calls.
+// It allows the passage of time to be controlled in tests.
+type Clock interface {
+	// AfterFunc waits for the duration to elapse and then calls f in its own
+	// goroutine.
+	// The returned function stops the call from happening and reports whether it
+	// was stopped before f was called.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+type systemClock struct{}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// FlushOption is used to configure the behavior of AutoFlush.
+type FlushOption func(*autoFlusher)
+
+// FlushDelay batches flushes Nagle-style: instead of flushing as soon as an
+// element ends, the flush is delayed by up to d so that elements written in
+// quick succession are flushed together.
+func FlushDelay(d time.Duration) FlushOption {
+	return func(a *autoFlusher) {
+		a.delay = d
+	}
+}
+
+// FlushPending flushes immediately once n elements are waiting to be flushed,
+// even if the delay set by FlushDelay has not elapsed.
+// If n <= 0, there is no limit.
+func FlushPending(n int) FlushOption {
+	return func(a *autoFlusher) {
+		a.maxPending = n
+	}
+}
+
+// FlushClock sets the clock used to schedule delayed flushes.
+// The default clock uses the time package.
+func FlushClock(c Clock) FlushOption {
+	return func(a *autoFlusher) {
+		a.clock = c
+	}
+}
+
+// AutoFlush returns a TokenWriteFlusher that calls w.Flush after each element
+// at the given depth has been written, where elements at the top level of the
+// stream are at depth 1.
+// For example, a depth of 2 flushes after each child of a long lived root
+// element.
+//
+// If a delay is configured with FlushDelay, flushes happen on a separate
+// goroutine and any error they return is reported by the next call to
+// EncodeToken or Flush.
+// Calling Flush always flushes immediately.
+// The returned writer is safe for concurrent use, but it does not prevent the
+// tokens of different elements from being interleaved.
+func AutoFlush(w TokenWriteFlusher, depth int, opts ...FlushOption) TokenWriteFlusher {
+	a := &autoFlusher{
+		w:     w,
+		depth: depth,
+		clock: systemClock{},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+type autoFlusher struct {
+	w          TokenWriteFlusher
+	depth      int
+	delay      time.Duration
+	maxPending int
+	clock      Clock
+
+	mu      sync.Mutex
+	level   int
+	pending int
+	gen     uint64
+	stop    func() bool
+	err     error
+}
+
+func (a *autoFlusher) EncodeToken(t xml.Token) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.takeErr(); err != nil {
+		return err
+	}
+
+	if err := a.w.EncodeToken(t); err != nil {
+		return err
+	}
+	switch t.(type) {
+	case xml.StartElement:
+		a.level++
+	case xml.EndElement:
+		a.level--
+		if a.level == a.depth-1 {
+			return a.elementDone()
+		}
+	}
+	return nil
+}
+
+func (a *autoFlusher) Flush() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.takeErr(); err != nil {
+		return err
+	}
+	return a.flush()
+}
+
+// takeErr returns and clears any error from a delayed flush.
+func (a *autoFlusher) takeErr() error {
+	err := a.err
+	a.err = nil
+	return err
+}
+
+// elementDone is called with the lock held each time an element at the flush
+// depth ends.
+func (a *autoFlusher) elementDone() error {
+	a.pending++
+	if a.delay <= 0 || (a.maxPending > 0 && a.pending >= a.maxPending) {
+		return a.flush()
+	}
+	if a.stop == nil {
+		gen := a.gen
+		a.stop = a.clock.AfterFunc(a.delay, func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			// If a flush already happened since this one was scheduled there is
+			// nothing to do.
+			if gen != a.gen {
+				return
+			}
+			if err := a.flush(); err != nil {
+				a.err = err
+			}
+		})
+	}
+	return nil
+}
+
+// flush is called with the lock held and flushes the underlying writer,
+// canceling any delayed flush.
+func (a *autoFlusher) flush() error {
+	if a.stop != nil {
+		a.stop()
+		a.stop = nil
+	}
+	a.gen++
+	a.pending = 0
+	return a.w.Flush()
+}
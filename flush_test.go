@@ -0,0 +1,103 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"strconv"
+	"testing"
+	"time"
+
+	"mellium.im/xmlstream"
+)
+
+// fakeClock is a Clock that only calls scheduled functions when fire is called.
+type fakeClock struct {
+	f     func()
+	delay time.Duration
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) func() bool {
+	c.f = f
+	c.delay = d
+	return func() bool {
+		stopped := c.f != nil
+		c.f = nil
+		return stopped
+	}
+}
+
+func (c *fakeClock) fire() {
+	f := c.f
+	c.f = nil
+	if f != nil {
+		f()
+	}
+}
+
+func writeElement(t *testing.T, w xmlstream.TokenWriter, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := w.EncodeToken(xml.StartElement{Name: xml.Name{Local: name}}); err != nil {
+			t.Fatalf("error encoding start: %v", err)
+		}
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		if err := w.EncodeToken(xml.EndElement{Name: xml.Name{Local: names[i]}}); err != nil {
+			t.Fatalf("error encoding end: %v", err)
+		}
+	}
+}
+
+var autoFlushTests = [...]struct {
+	depth    int
+	opts     []xmlstream.FlushOption
+	elements int
+	before   int
+	after    int
+}{
+	0: {depth: 1, elements: 3, before: 0, after: 0},
+	1: {depth: 2, elements: 3, before: 3, after: 3},
+	2: {depth: 3, elements: 3, before: 3, after: 3},
+	3: {
+		depth:    2,
+		opts:     []xmlstream.FlushOption{xmlstream.FlushDelay(time.Second)},
+		elements: 3,
+		before:   0,
+		after:    1,
+	},
+	4: {
+		depth:    2,
+		opts:     []xmlstream.FlushOption{xmlstream.FlushDelay(time.Second), xmlstream.FlushPending(2)},
+		elements: 5,
+		before:   2,
+		after:    3,
+	},
+}
+
+func TestAutoFlush(t *testing.T) {
+	for i, tc := range autoFlushTests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			rec := &flushRecorder{TokenWriter: xmlstream.Discard()}
+			clock := &fakeClock{}
+			w := xmlstream.AutoFlush(rec, tc.depth, append(tc.opts, xmlstream.FlushClock(clock))...)
+
+			start := xml.StartElement{Name: xml.Name{Local: "stream"}}
+			if err := w.EncodeToken(start); err != nil {
+				t.Fatalf("error encoding stream start: %v", err)
+			}
+			for j := 0; j < tc.elements; j++ {
+				writeElement(t, w, "message", "body")
+			}
+			if rec.flushes != tc.before {
+				t.Errorf("wrong number of flushes before timer: want=%d, got=%d", tc.before, rec.flushes)
+			}
+			clock.fire()
+			if rec.flushes != tc.after {
+				t.Errorf("wrong number of flushes after timer: want=%d, got=%d", tc.after, rec.flushes)
+			}
+		})
+	}
+}
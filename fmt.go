@@ -7,17 +7,45 @@ package xmlstream
 import (
 	"bytes"
 	"encoding/xml"
+	"io"
+	"sort"
 )
 
+const xmlNS = "http://www.w3.org/XML/1998/namespace"
+
 // Fmt returns a transformer that indents the given XML stream.
 // The default indentation style is to remove non-significant whitespace, start
 // elements on a new line and indent two spaces per level.
+//
+// Because Fmt works by adding and removing whitespace tokens, it cannot change
+// the layout inside a start tag.
+// To also wrap attributes or limit the width of lines use Format.
 func Fmt(d xml.TokenReader, opts ...FmtOption) xml.TokenReader {
-	f := &fmter{d: whitespaceRemover(d)}
+	f := &fmter{d: d}
 	f.getOpts(opts)
 	return f
 }
 
+// Format formats the tokens read from r with Fmt and writes them to w using an
+// XMLWriter configured with any XMLOptions.
+// This lets the layout of start tags be controlled along with the whitespace
+// between tokens, for example:
+//
+//	Format(w, r, Indent("\t"), XMLOptions(WrapAttr(80)))
+//
+// Format returns an error if r ends before all elements have been closed.
+// Anything formatted before an error occurred is still written to w.
+func Format(w io.Writer, r xml.TokenReader, opts ...FmtOption) error {
+	f := &fmter{d: r}
+	f.getOpts(opts)
+	xw := XMLWriter(w, f.xmlOpts...)
+	if _, err := Copy(xw, f); err != nil {
+		xw.Flush()
+		return err
+	}
+	return xw.Close()
+}
+
 // FmtOption is used to configure a formatters behavior.
 type FmtOption func(*fmter)
 
@@ -45,13 +73,125 @@ func Indent(s string) FmtOption {
 	}
 }
 
+// FmtStrategy controls where a formatter considers whitespace to be
+// significant.
+// Whitespace is never added to or removed from the contents of an element where
+// it is significant.
+type FmtStrategy uint8
+
+// A list of formatting strategies.
+const (
+	// IndentAll treats all whitespace as insignificant.
+	// Whitespace only character data is removed and every token is indented.
+	// This is the default.
+	IndentAll FmtStrategy = iota
+
+	// PreserveSpace treats whitespace as significant in elements that have an
+	// xml:space="preserve" attribute and in their children, unless they are
+	// reset with xml:space="default".
+	PreserveSpace
+
+	// PreserveMixed is like PreserveSpace except that whitespace is also
+	// significant in any element that contains non-whitespace character data
+	// (such as <p>Hello <b>you</b>!</p>) and in all of its children.
+	// To find out if an element contains character data the formatter may have
+	// to buffer the entire element.
+	PreserveMixed
+)
+
+// Strategy sets the strategy used to decide where whitespace is significant.
+func Strategy(s FmtStrategy) FmtOption {
+	return func(f *fmter) {
+		f.strategy = s
+	}
+}
+
+// SortAttr sorts the attributes of every start element in the stream.
+// Namespace declarations are sorted first, followed by all other attributes
+// ordered by namespace and then local name.
+func SortAttr() FmtOption {
+	return func(f *fmter) {
+		f.sortAttr = true
+	}
+}
+
+// CollapseEmpty writes elements that have no content (other than insignificant
+// whitespace) on a single line.
+// Because encoding/xml always writes end elements, an empty element such as
+// <br/> is still written as <br></br>.
+func CollapseEmpty() FmtOption {
+	return func(f *fmter) {
+		f.collapse = true
+	}
+}
+
+// InlineText writes elements that contain only character data of at most n
+// bytes on a single line without adding whitespace around the text.
+// If n <= 0 text only elements are always written on a single line.
+func InlineText(n int) FmtOption {
+	return func(f *fmter) {
+		f.inline = true
+		f.inlineMax = n
+	}
+}
+
+// AttachComments indents comments to the same level as the token that follows
+// them so that a comment describing an element stays visually attached to it.
+// Without this option a comment directly before an end element is indented as
+// if it were a child of the element being closed.
+func AttachComments() FmtOption {
+	return func(f *fmter) {
+		f.attach = true
+	}
+}
+
+// Prolog writes the XML declaration and any directives (such as a DOCTYPE)
+// that appear before the first element on their own lines without a prefix,
+// regardless of the Prefix and Suffix options.
+func Prolog() FmtOption {
+	return func(f *fmter) {
+		f.prolog = true
+	}
+}
+
+// XMLOptions configures the XMLWriter used by Format, for example to wrap
+// attributes with WrapAttr or limit the width of lines with LineWidth.
+// It has no effect on Fmt.
+func XMLOptions(opts ...XMLWriterOption) FmtOption {
+	return func(f *fmter) {
+		f.xmlOpts = append(f.xmlOpts, opts...)
+	}
+}
+
+// fmtScope is the formatting state of an open element.
+type fmtScope struct {
+	space bool
+	mixed bool
+}
+
+func (s fmtScope) significant() bool {
+	return s.space || s.mixed
+}
+
 type fmter struct {
-	d       xml.TokenReader
-	nesting int
-	indent  []byte
-	prefix  []byte
-	suffix  []byte
-	queue   []xml.Token
+	d         xml.TokenReader
+	nesting   int
+	indent    []byte
+	prefix    []byte
+	suffix    []byte
+	strategy  FmtStrategy
+	sortAttr  bool
+	collapse  bool
+	inline    bool
+	inlineMax int
+	attach    bool
+	prolog    bool
+	xmlOpts   []XMLWriterOption
+	started   bool
+	scopes    []fmtScope
+	queue     []xml.Token
+	ahead     []xml.Token
+	aheadErr  error
 }
 
 func (f *fmter) addIndent(toks []xml.Token) []xml.Token {
@@ -62,6 +202,189 @@ func (f *fmter) addIndent(toks []xml.Token) []xml.Token {
 	return toks
 }
 
+// scope returns the formatting state of the innermost open element.
+func (f *fmter) scope() fmtScope {
+	if len(f.scopes) == 0 {
+		return fmtScope{}
+	}
+	return f.scopes[len(f.scopes)-1]
+}
+
+// next returns the next token from the lookahead buffer or the underlying
+// reader.
+func (f *fmter) next() (xml.Token, error) {
+	if len(f.ahead) > 0 {
+		var t xml.Token
+		t, f.ahead = f.ahead[0], f.ahead[1:]
+		return t, nil
+	}
+	if f.aheadErr != nil {
+		return nil, f.aheadErr
+	}
+	return f.d.Token()
+}
+
+// peek makes sure that at least n tokens are in the lookahead buffer and
+// reports whether it was successful.
+func (f *fmter) peek(n int) bool {
+	for len(f.ahead) < n {
+		if f.aheadErr != nil {
+			return false
+		}
+		t, err := f.d.Token()
+		if t != nil {
+			f.ahead = append(f.ahead, xml.CopyToken(t))
+		}
+		if err != nil {
+			f.aheadErr = err
+		} else if t == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// mixed looks ahead to determine whether the element that was just started
+// contains non-whitespace character data.
+func (f *fmter) mixed() bool {
+	depth := 0
+	for i := 0; f.peek(i + 1); i++ {
+		switch t := f.ahead[i].(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case xml.CharData:
+			if depth == 0 && !isWhitespace(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// oneLine looks ahead to determine whether the element that was just started
+// should be written on a single line.
+// It returns the number of tokens in the lookahead buffer that make up the
+// remainder of the element (including its end element), or 0.
+func (f *fmter) oneLine() int {
+	if !f.collapse && !f.inline {
+		return 0
+	}
+	var text int
+	empty := true
+	for i := 0; f.peek(i + 1); i++ {
+		switch t := f.ahead[i].(type) {
+		case xml.CharData:
+			if !isWhitespace(t) {
+				empty = false
+			}
+			text += len(t)
+		case xml.EndElement:
+			switch {
+			case empty && f.collapse:
+				return i + 1
+			case !empty && f.inline && (f.inlineMax <= 0 || text <= f.inlineMax):
+				return i + 1
+			}
+			return 0
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+// textRun looks ahead to determine whether the character data that was just
+// read is followed by comments or processing instructions that sit inside the
+// text.
+// It returns the number of tokens in the lookahead buffer that should be
+// written on the same line as the character data, or 0.
+func (f *fmter) textRun() int {
+	var n int
+	for i := 0; f.peek(i + 1); i++ {
+		switch t := f.ahead[i].(type) {
+		case xml.Comment, xml.ProcInst:
+			continue
+		case xml.CharData:
+			if i > n && !isWhitespace(t) {
+				n = i + 1
+				continue
+			}
+		}
+		return n
+	}
+	return n
+}
+
+// nextIsEnd reports whether the next significant token is an end element.
+func (f *fmter) nextIsEnd() bool {
+	for i := 0; f.peek(i + 1); i++ {
+		switch t := f.ahead[i].(type) {
+		case xml.CharData:
+			if isWhitespace(t) {
+				continue
+			}
+		case xml.EndElement:
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+func sortAttr(start xml.StartElement) xml.StartElement {
+	attr := make([]xml.Attr, len(start.Attr))
+	copy(attr, start.Attr)
+	isNS := func(a xml.Attr) bool {
+		return a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns")
+	}
+	sort.SliceStable(attr, func(i, j int) bool {
+		a, b := attr[i], attr[j]
+		if nsA, nsB := isNS(a), isNS(b); nsA != nsB {
+			return nsA
+		}
+		if a.Name.Space != b.Name.Space {
+			return a.Name.Space < b.Name.Space
+		}
+		return a.Name.Local < b.Name.Local
+	})
+	start.Attr = attr
+	return start
+}
+
+// push opens a new scope for start.
+func (f *fmter) push(start xml.StartElement) fmtScope {
+	parent := f.scope()
+	s := fmtScope{space: parent.space, mixed: parent.mixed}
+	if f.strategy >= PreserveSpace {
+		for _, attr := range start.Attr {
+			if attr.Name.Local == "space" && (attr.Name.Space == xmlNS || attr.Name.Space == "xml") {
+				switch attr.Value {
+				case "preserve":
+					s.space = true
+				case "default":
+					s.space = false
+				}
+			}
+		}
+	}
+	if f.strategy >= PreserveMixed && !s.significant() {
+		s.mixed = f.mixed()
+	}
+	f.scopes = append(f.scopes, s)
+	return s
+}
+
+func (f *fmter) pop() {
+	if len(f.scopes) > 0 {
+		f.scopes = f.scopes[:len(f.scopes)-1]
+	}
+}
+
 func (f *fmter) Token() (t xml.Token, err error) {
 	// If we've queued up a token to write next, go ahead and pop the next token
 	// off the queue.
@@ -70,41 +393,144 @@ func (f *fmter) Token() (t xml.Token, err error) {
 		return
 	}
 
-	t, err = f.d.Token()
-	if err != nil {
-		return t, err
+	for {
+		t, err = f.next()
+		if err != nil {
+			return t, err
+		}
+		// Remove insignificant whitespace.
+		if chars, ok := t.(xml.CharData); ok && isWhitespace(chars) && !f.scope().significant() {
+			continue
+		}
+		break
+	}
+
+	// Whitespace may only be added before and after the token if it is not
+	// significant in the surrounding element.
+	// Start and end elements are on the boundary between two elements, so their
+	// inner side is governed by their own scope.
+	var before, after bool
+	var inner []xml.Token
+	switch tok := t.(type) {
+	case xml.StartElement:
+		if f.sortAttr {
+			t = sortAttr(tok)
+		}
+		before = !f.scope().significant()
+		after = !f.push(tok).significant()
+		if !after {
+			break
+		}
+		if n := f.oneLine(); n > 0 {
+			// The whole element is written at once, so its end element is handled
+			// here instead of on a later call to Token.
+			for _, tok := range f.ahead[:n] {
+				if chars, ok := tok.(xml.CharData); ok && isWhitespace(chars) {
+					continue
+				}
+				inner = append(inner, tok)
+			}
+			f.ahead = f.ahead[n:]
+			f.pop()
+			after = !f.scope().significant()
+		}
+	case xml.EndElement:
+		before = !f.scope().significant()
+		f.pop()
+		after = !f.scope().significant()
+	case xml.CharData:
+		before = !f.scope().significant()
+		after = before
+		if before {
+			// Looking ahead may overwrite the token if it came directly from an
+			// xml.Decoder.
+			t = tok.Copy()
+			n := f.textRun()
+			inner = append(inner, f.ahead[:n]...)
+			f.ahead = f.ahead[n:]
+		}
+	default:
+		before = !f.scope().significant()
+		after = before
+	}
+
+	// The prolog is not part of any element so it is never prefixed or indented.
+	prolog := false
+	if !f.started {
+		switch tok := t.(type) {
+		case xml.ProcInst:
+			prolog = tok.Target == "xml" || f.prolog
+		case xml.Directive:
+			prolog = f.prolog
+		case xml.StartElement:
+			f.started = true
+		}
 	}
 
 	toks := []xml.Token{}
 
+	if prolog {
+		toks = append(toks, t)
+		if f.prolog {
+			toks = append(toks, xml.CharData{'\n'})
+		} else if len(f.suffix) > 0 {
+			toks = append(toks, xml.CharData(f.suffix))
+		}
+		f.queue = append(f.queue, toks[1:]...)
+		return toks[0], nil
+	}
+
 	// Add prefix
-	if len(f.prefix) > 0 {
+	if before && len(f.prefix) > 0 {
 		toks = append(toks, xml.CharData(f.prefix))
 	}
 
 	// Add indentation
-	switch t.(type) {
+	switch tok := t.(type) {
 	case xml.CharData:
 		// Don't indent chardata
 	case xml.EndElement:
 		// Decrease the indentation level.
 		f.nesting--
 
-		toks = f.addIndent(toks)
+		if before {
+			toks = f.addIndent(toks)
+		}
 	case xml.StartElement:
-		toks = f.addIndent(toks)
+		if before {
+			toks = f.addIndent(toks)
+		}
 
-		// Increase the indentation level.
-		f.nesting++
+		// Increase the indentation level unless the entire element is being
+		// written on one line.
+		if len(inner) == 0 {
+			f.nesting++
+		}
+	case xml.Comment:
+		if before {
+			t = tok.Copy()
+			// An attached comment before an end element is indented to the same level
+			// as the end element.
+			if f.attach && f.nesting > 0 && f.nextIsEnd() {
+				f.nesting--
+				toks = f.addIndent(toks)
+				f.nesting++
+				break
+			}
+			toks = f.addIndent(toks)
+		}
 	default:
-		toks = f.addIndent(toks)
+		if before {
+			toks = f.addIndent(toks)
+		}
 	}
 
 	// Add original token
 	toks = append(toks, t)
+	toks = append(toks, inner...)
 
 	// Add suffix
-	if len(f.suffix) > 0 {
+	if after && len(f.suffix) > 0 {
 		toks = append(toks, xml.CharData(f.suffix))
 	}
 
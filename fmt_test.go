@@ -0,0 +1,153 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+var fmtTests = [...]struct {
+	in   string
+	opts []xmlstream.FmtOption
+	out  string
+}{
+	0: {},
+	1: {
+		in:  `<a> <b>text</b></a>`,
+		out: "<a>\n  <b>\ntext\n  </b>\n</a>\n",
+	},
+	2: {
+		in:   `<a> <b>text</b></a>`,
+		opts: []xmlstream.FmtOption{xmlstream.Strategy(xmlstream.PreserveSpace)},
+		out:  "<a>\n  <b>\ntext\n  </b>\n</a>\n",
+	},
+	3: {
+		in:   `<a xml:space="preserve"> <b> text</b></a><c> </c>`,
+		opts: []xmlstream.FmtOption{xmlstream.Strategy(xmlstream.PreserveSpace)},
+		out:  "<a xml:space=\"preserve\"> <b> text</b></a>\n<c>\n</c>\n",
+	},
+	4: {
+		in:   `<a xml:space="preserve"> <b xml:space="default"> <c/></b></a>`,
+		opts: []xmlstream.FmtOption{xmlstream.Strategy(xmlstream.PreserveSpace)},
+		out:  "<a xml:space=\"preserve\"> <b xml:space=\"default\">\n    <c>\n    </c>\n  </b></a>\n",
+	},
+	5: {
+		in:   `<doc><p>Hello <b>you</b>!</p> <p><i> a </i></p></doc>`,
+		opts: []xmlstream.FmtOption{xmlstream.Strategy(xmlstream.PreserveMixed)},
+		out:  "<doc>\n  <p>Hello <b>you</b>!</p>\n  <p>\n    <i> a </i>\n  </p>\n</doc>\n",
+	},
+	6: {
+		in:   `<doc><p>Hello <b xml:space="default"> <i/> </b></p></doc>`,
+		opts: []xmlstream.FmtOption{xmlstream.Strategy(xmlstream.PreserveMixed)},
+		out:  "<doc>\n  <p>Hello <b xml:space=\"default\"> <i></i> </b></p>\n</doc>\n",
+	},
+	7: {
+		in:   `<a><b> </b><c>text</c><d>longer text</d><e><f/></e></a>`,
+		opts: []xmlstream.FmtOption{xmlstream.CollapseEmpty(), xmlstream.InlineText(4)},
+		out:  "<a>\n  <b></b>\n  <c>text</c>\n  <d>\nlonger text\n  </d>\n  <e>\n    <f></f>\n  </e>\n</a>\n",
+	},
+	8: {
+		in:   `<a><b>text</b></a>`,
+		opts: []xmlstream.FmtOption{xmlstream.InlineText(0), xmlstream.Prefix("# ")},
+		out:  "# <a>\n#   <b>text</b>\n# </a>\n",
+	},
+	9: {
+		in:   `<a z="1" y="2" x="3"></a>`,
+		opts: []xmlstream.FmtOption{xmlstream.SortAttr(), xmlstream.CollapseEmpty()},
+		out:  "<a x=\"3\" y=\"2\" z=\"1\"></a>\n",
+	},
+	10: {
+		in:  `<a><p>Hello <!-- x --><?pi?> world<!-- y --></p></a>`,
+		out: "<a>\n  <p>\nHello <!-- x --><?pi?> world\n    <!-- y -->\n  </p>\n</a>\n",
+	},
+	11: {
+		in:   `<a><!-- b --><b/><!-- end --></a>`,
+		opts: []xmlstream.FmtOption{xmlstream.AttachComments()},
+		out:  "<a>\n  <!-- b -->\n  <b>\n  </b>\n<!-- end -->\n</a>\n",
+	},
+	12: {
+		in:   `<?xml version="1.0"?><!DOCTYPE a><a/>`,
+		opts: []xmlstream.FmtOption{xmlstream.Prefix("# "), xmlstream.Suffix(""), xmlstream.Prolog()},
+		out:  "<?xml version=\"1.0\"?>\n<!DOCTYPE a>\n# <a># </a>",
+	},
+	13: {
+		in:   `<?xml version="1.0"?><a/>`,
+		opts: []xmlstream.FmtOption{xmlstream.Prefix("# ")},
+		out:  "<?xml version=\"1.0\"?>\n# <a>\n# </a>\n",
+	},
+}
+
+func TestFmt(t *testing.T) {
+	for i, tc := range fmtTests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r := xmlstream.Fmt(xml.NewDecoder(strings.NewReader(tc.in)), tc.opts...)
+			var buf strings.Builder
+			e := xml.NewEncoder(&buf)
+			if _, err := xmlstream.Copy(e, r); err != nil {
+				t.Fatalf("error encoding: %v", err)
+			}
+			if err := e.Flush(); err != nil {
+				t.Fatalf("error flushing: %v", err)
+			}
+			if out := buf.String(); out != tc.out {
+				t.Errorf("wrong output:\nwant=%q,\n got=%q", tc.out, out)
+			}
+		})
+	}
+}
+
+var formatTests = [...]struct {
+	in   string
+	opts []xmlstream.FmtOption
+	out  string
+	err  bool
+}{
+	0: {},
+	1: {
+		in: `<a><iq type="get" id="1" to="a@b"/></a>`,
+		opts: []xmlstream.FmtOption{
+			xmlstream.CollapseEmpty(),
+			xmlstream.XMLOptions(xmlstream.WrapAttr(20), xmlstream.SelfClose()),
+		},
+		out: "<a>\n  <iq type=\"get\"\n      id=\"1\"\n      to=\"a@b\"/>\n</a>\n",
+	},
+	2: {
+		in: `<a><b c="d" e="f"/></a>`,
+		opts: []xmlstream.FmtOption{
+			xmlstream.Indent("\t"),
+			xmlstream.XMLOptions(xmlstream.LineWidth(12)),
+		},
+		out: "<a>\n\t<b c=\"d\"\n\t   e=\"f\">\n\t</b>\n</a>\n",
+	},
+	3: {
+		in:  `<a><b>`,
+		out: "<a>\n  <b>\n",
+		err: true,
+	},
+}
+
+func TestFormat(t *testing.T) {
+	for i, tc := range formatTests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := xmlstream.Format(&buf, xml.NewDecoder(strings.NewReader(tc.in)), tc.opts...)
+			switch {
+			case tc.err && err == nil:
+				t.Errorf("expected error formatting")
+			case !tc.err && err != nil:
+				t.Errorf("error formatting: %v", err)
+			}
+			if out := buf.String(); out != tc.out {
+				t.Errorf("wrong output:\nwant=%q,\n got=%q", tc.out, out)
+			}
+		})
+	}
+}
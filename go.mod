@@ -1,5 +1,5 @@
 module mellium.im/xmlstream
 
-go 1.18
+go 1.20
 
 require mellium.im/reader v0.1.0
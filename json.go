@@ -0,0 +1,636 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// JSONConvention determines how XML is mapped to JSON and back.
+type JSONConvention uint8
+
+// A list of supported conventions.
+//
+// With all conventions, consecutive sibling elements with the same name are
+// grouped into an array and any other elements become members of the object
+// that represents their parent.
+// Because conversion is streaming, siblings with the same name that are
+// separated by other elements result in duplicate keys.
+// The character data of an element is concatenated and whitespace only
+// character data is ignored.
+// Comments, processing instructions, and directives are dropped.
+const (
+	// BadgerFish maps every element to an object.
+	// Attributes are stored in members prefixed with "@", text is stored in the
+	// "$" member, and namespace declarations are stored in an "@xmlns" object
+	// that maps prefixes to namespaces with "$" used for the default namespace.
+	//
+	//	<a xmlns="urn:a" b="c">d</a>
+	//	{"a":{"@xmlns":{"$":"urn:a"},"@b":"c","$":"d"}}
+	BadgerFish JSONConvention = iota
+
+	// Parker drops attributes and namespaces and maps elements that only contain
+	// text to strings, empty elements to null, and all other elements to
+	// objects.
+	// The root element is not included in the output.
+	//
+	//	<a><b>c</b><b/></a>
+	//	{"b":["c",null]}
+	Parker
+
+	// AttrText is like Parker except that the root element is kept and elements
+	// with attributes become objects with attributes stored in members prefixed
+	// with "@" and text stored in a "#text" member.
+	// Namespace declarations are treated as attributes.
+	//
+	//	<a xmlns="urn:a"><b c="d">e</b><f>g</f></a>
+	//	{"a":{"@xmlns":"urn:a","b":{"@c":"d","#text":"e"},"f":"g"}}
+	AttrText
+)
+
+func (c JSONConvention) textKey() string {
+	if c == AttrText {
+		return "#text"
+	}
+	return "$"
+}
+
+const (
+	runNone = iota
+	runPending
+	runArray
+)
+
+// jsonElem is the state of an element that is being converted to JSON.
+type jsonElem struct {
+	key     string
+	sink    io.Writer
+	object  bool
+	members int
+	text    []byte
+	ns      map[string]string
+	run     int
+	runKey  string
+	runBuf  *bytes.Buffer
+}
+
+// JSONWriter returns a TokenWriter that converts the tokens written to it to
+// JSON using the given convention and writes the result to w.
+// Each top level element is written as a separate JSON value followed by a
+// newline.
+//
+// Output is buffered and must be flushed.
+// The first of any consecutive siblings with the same name is held back until
+// the next sibling is written so that it can be placed in an array if
+// necessary; no other output is held back.
+func JSONWriter(w io.Writer, conv JSONConvention) TokenWriteFlusher {
+	j := &jsonWriter{
+		w:    bufio.NewWriter(w),
+		conv: conv,
+	}
+	j.quote = json.NewEncoder(&j.scratch)
+	j.quote.SetEscapeHTML(false)
+	return j
+}
+
+type jsonWriter struct {
+	w       *bufio.Writer
+	conv    JSONConvention
+	stack   []*jsonElem
+	scratch bytes.Buffer
+	quote   *json.Encoder
+	err     error
+}
+
+func (j *jsonWriter) write(w io.Writer, s string) {
+	if j.err != nil {
+		return
+	}
+	_, j.err = io.WriteString(w, s)
+}
+
+func (j *jsonWriter) writeString(w io.Writer, s string) {
+	j.scratch.Reset()
+	if err := j.quote.Encode(s); err != nil {
+		j.err = err
+		return
+	}
+	j.write(w, strings.TrimSuffix(j.scratch.String(), "\n"))
+}
+
+// member writes the key of a new member of e's object.
+func (j *jsonWriter) member(e *jsonElem, key string) {
+	if e.members > 0 {
+		j.write(e.sink, ",")
+	}
+	e.members++
+	j.writeString(e.sink, key)
+	j.write(e.sink, ":")
+}
+
+func (j *jsonWriter) openObject(e *jsonElem) {
+	if !e.object {
+		e.object = true
+		j.write(e.sink, "{")
+	}
+}
+
+// lookupPrefix returns the prefix bound to a namespace in the current scope.
+func (j *jsonWriter) lookupPrefix(space string) (string, bool) {
+	if space == xmlNS {
+		return "xml", true
+	}
+	shadowed := make(map[string]bool)
+	for i := len(j.stack) - 1; i >= 0; i-- {
+		for prefix, uri := range j.stack[i].ns {
+			if uri == space && !shadowed[prefix] {
+				return prefix, true
+			}
+		}
+		for prefix := range j.stack[i].ns {
+			shadowed[prefix] = true
+		}
+	}
+	return "", false
+}
+
+// qname returns the JSON key for an element or attribute name.
+func (j *jsonWriter) qname(name xml.Name, attr bool) string {
+	switch {
+	case name.Space == "":
+		return name.Local
+	case attr && name.Space == "xmlns":
+		return "xmlns:" + name.Local
+	}
+	prefix, ok := j.lookupPrefix(name.Space)
+	switch {
+	case ok && prefix == "" && !attr:
+		return name.Local
+	case ok && prefix != "":
+		return prefix + ":" + name.Local
+	case !ok && !strings.ContainsAny(name.Space, ":/"):
+		// The name appears to be from a raw token.
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+// beginChild prepares e's object for a child with the given key and returns the
+// writer that the child should be written to.
+func (j *jsonWriter) beginChild(e *jsonElem, key string) io.Writer {
+	switch {
+	case e.run == runPending && e.runKey == key:
+		j.member(e, key)
+		j.write(e.sink, "[")
+		j.write(e.sink, e.runBuf.String())
+		j.write(e.sink, ",")
+		e.run = runArray
+		e.runBuf = nil
+		return e.sink
+	case e.run == runArray && e.runKey == key:
+		j.write(e.sink, ",")
+		return e.sink
+	}
+	j.endRun(e)
+	e.run = runPending
+	e.runKey = key
+	e.runBuf = new(bytes.Buffer)
+	return e.runBuf
+}
+
+// endRun writes out any children of e that were held back.
+func (j *jsonWriter) endRun(e *jsonElem) {
+	switch e.run {
+	case runPending:
+		j.member(e, e.runKey)
+		j.write(e.sink, e.runBuf.String())
+	case runArray:
+		j.write(e.sink, "]")
+	}
+	e.run = runNone
+	e.runBuf = nil
+}
+
+func (j *jsonWriter) start(start xml.StartElement) {
+	var parent *jsonElem
+	if len(j.stack) > 0 {
+		parent = j.stack[len(j.stack)-1]
+	}
+	e := &jsonElem{ns: make(map[string]string)}
+	var attrs []xml.Attr
+	// Namespace declarations in the order they appeared.
+	var decls [][2]string
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			decls = append(decls, [2]string{"", attr.Value})
+		case attr.Name.Space == "xmlns":
+			decls = append(decls, [2]string{attr.Name.Local, attr.Value})
+		default:
+			attrs = append(attrs, attr)
+			continue
+		}
+		e.ns[decls[len(decls)-1][0]] = attr.Value
+		if j.conv == AttrText {
+			attrs = append(attrs, attr)
+		}
+	}
+	j.stack = append(j.stack, e)
+
+	// If the namespace of the element was never declared, declare it.
+	if _, ok := j.lookupPrefix(start.Name.Space); !ok && strings.ContainsAny(start.Name.Space, ":/") {
+		e.ns[""] = start.Name.Space
+		decls = append(decls, [2]string{"", start.Name.Space})
+		if j.conv == AttrText {
+			attrs = append([]xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: start.Name.Space}}, attrs...)
+		}
+	}
+	e.key = j.qname(start.Name, false)
+
+	if parent == nil {
+		e.sink = j.w
+		if j.conv != Parker {
+			j.write(e.sink, "{")
+			j.writeString(e.sink, e.key)
+			j.write(e.sink, ":")
+		}
+	} else {
+		j.openObject(parent)
+		e.sink = j.beginChild(parent, e.key)
+	}
+
+	switch j.conv {
+	case BadgerFish:
+		j.openObject(e)
+		if len(decls) > 0 {
+			j.member(e, "@xmlns")
+			j.write(e.sink, "{")
+			for i, decl := range decls {
+				if i > 0 {
+					j.write(e.sink, ",")
+				}
+				prefix := decl[0]
+				if prefix == "" {
+					prefix = "$"
+				}
+				j.writeString(e.sink, prefix)
+				j.write(e.sink, ":")
+				j.writeString(e.sink, decl[1])
+			}
+			j.write(e.sink, "}")
+		}
+	case Parker:
+		attrs = nil
+	}
+	if len(attrs) > 0 {
+		j.openObject(e)
+	}
+	for _, attr := range attrs {
+		j.member(e, "@"+j.qname(attr.Name, true))
+		j.writeString(e.sink, attr.Value)
+	}
+}
+
+func (j *jsonWriter) end() {
+	if len(j.stack) == 0 {
+		j.err = errors.New("xmlstream: end element without start element")
+		return
+	}
+	e := j.stack[len(j.stack)-1]
+	j.endRun(e)
+	text := e.text
+	if isWhitespace(text) {
+		text = nil
+	}
+	switch {
+	case e.object:
+		if text != nil && j.conv != Parker {
+			j.member(e, j.conv.textKey())
+			j.writeString(e.sink, string(text))
+		}
+		j.write(e.sink, "}")
+	case text != nil:
+		j.writeString(e.sink, string(text))
+	default:
+		j.write(e.sink, "null")
+	}
+	j.stack = j.stack[:len(j.stack)-1]
+	if len(j.stack) == 0 {
+		if j.conv != Parker {
+			j.write(e.sink, "}")
+		}
+		j.write(e.sink, "\n")
+	}
+}
+
+func (j *jsonWriter) EncodeToken(t xml.Token) error {
+	if j.err != nil {
+		return j.err
+	}
+	switch tok := t.(type) {
+	case xml.StartElement:
+		j.start(tok)
+	case xml.EndElement:
+		j.end()
+	case xml.CharData:
+		if len(j.stack) > 0 {
+			e := j.stack[len(j.stack)-1]
+			e.text = append(e.text, tok...)
+		}
+	}
+	return j.err
+}
+
+func (j *jsonWriter) Flush() error {
+	if j.err != nil {
+		return j.err
+	}
+	return j.w.Flush()
+}
+
+// jsonScope is a set of namespace declarations and its enclosing scope.
+type jsonScope struct {
+	parent *jsonScope
+	ns     map[string]string
+}
+
+func (s *jsonScope) lookup(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return xmlNS, true
+	}
+	for ; s != nil; s = s.parent {
+		if uri, ok := s.ns[prefix]; ok {
+			return uri, true
+		}
+	}
+	return "", false
+}
+
+// JSONReader returns a reader that converts JSON read from d to XML tokens using
+// the given convention.
+// Each top level JSON value is converted to an element.
+// Because the Parker convention does not record the name of the root element,
+// each value is wrapped in an element with the name root; other conventions
+// ignore root.
+//
+// Attributes must appear before any child elements or text in each JSON object.
+// The names of elements and attributes are resolved against the namespace
+// declarations in the JSON and set in the Space field of the tokens, but the
+// declarations themselves are not included as attributes; an xml.Encoder will
+// add them as necessary.
+// The returned reader must be closed if it is not read to the end.
+func JSONReader(d *json.Decoder, conv JSONConvention, root xml.Name) TokenReadCloser {
+	pr, pw := Pipe()
+	c := &jsonReader{d: d, conv: conv, root: root, w: pw}
+	go func() {
+		pw.CloseWithError(c.run())
+	}()
+	return pr
+}
+
+type jsonReader struct {
+	d    *json.Decoder
+	conv JSONConvention
+	root xml.Name
+	w    TokenWriter
+}
+
+func (c *jsonReader) delim(want json.Delim) error {
+	tok, err := c.d.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("xmlstream: expected %v in JSON, found %v", want, tok)
+	}
+	return nil
+}
+
+func (c *jsonReader) key() (string, error) {
+	tok, err := c.d.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("xmlstream: expected JSON object key, found %v", tok)
+	}
+	return key, nil
+}
+
+func (c *jsonReader) run() error {
+	for {
+		tok, err := c.d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if c.conv == Parker {
+			if err := c.element(c.root.Local, tok, &jsonScope{ns: map[string]string{"": c.root.Space}}); err != nil {
+				return err
+			}
+			continue
+		}
+		if tok != json.Delim('{') {
+			return fmt.Errorf("xmlstream: expected JSON object, found %v", tok)
+		}
+		for c.d.More() {
+			key, err := c.key()
+			if err != nil {
+				return err
+			}
+			tok, err := c.d.Token()
+			if err != nil {
+				return err
+			}
+			if err := c.element(key, tok, nil); err != nil {
+				return err
+			}
+		}
+		if err := c.delim('}'); err != nil {
+			return err
+		}
+	}
+}
+
+func jsonText(tok json.Token) (string, error) {
+	switch v := tok.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case nil:
+		return "", nil
+	}
+	return "", fmt.Errorf("xmlstream: expected JSON scalar, found %v", tok)
+}
+
+// name resolves a JSON key to an XML name.
+func jsonName(key string, scope *jsonScope, attr bool) xml.Name {
+	prefix, local, ok := strings.Cut(key, ":")
+	if !ok {
+		local, prefix = prefix, ""
+		if attr {
+			return xml.Name{Local: local}
+		}
+	}
+	space, found := scope.lookup(prefix)
+	if !found && prefix != "" {
+		return xml.Name{Local: key}
+	}
+	return xml.Name{Space: space, Local: local}
+}
+
+// attr reads the value of an attribute member and adds it to the scope or the
+// list of attributes.
+func (c *jsonReader) attr(key string, scope *jsonScope, attrs [][2]string) ([][2]string, error) {
+	tok, err := c.d.Token()
+	if err != nil {
+		return attrs, err
+	}
+	switch {
+	case key == "xmlns" && tok == json.Delim('{'):
+		for c.d.More() {
+			prefix, err := c.key()
+			if err != nil {
+				return attrs, err
+			}
+			tok, err := c.d.Token()
+			if err != nil {
+				return attrs, err
+			}
+			uri, err := jsonText(tok)
+			if err != nil {
+				return attrs, err
+			}
+			if prefix == "$" {
+				prefix = ""
+			}
+			scope.ns[prefix] = uri
+		}
+		return attrs, c.delim('}')
+	}
+	v, err := jsonText(tok)
+	if err != nil {
+		return attrs, err
+	}
+	switch {
+	case key == "xmlns":
+		scope.ns[""] = v
+	case strings.HasPrefix(key, "xmlns:"):
+		scope.ns[key[len("xmlns:"):]] = v
+	default:
+		attrs = append(attrs, [2]string{key, v})
+	}
+	return attrs, nil
+}
+
+// element converts the JSON value that starts with tok to an element with the
+// given key.
+func (c *jsonReader) element(key string, tok json.Token, parent *jsonScope) error {
+	if tok == json.Delim('[') {
+		for c.d.More() {
+			tok, err := c.d.Token()
+			if err != nil {
+				return err
+			}
+			if err := c.element(key, tok, parent); err != nil {
+				return err
+			}
+		}
+		return c.delim(']')
+	}
+
+	scope := &jsonScope{parent: parent, ns: make(map[string]string)}
+	var attrs [][2]string
+	var start xml.StartElement
+	started := false
+	begin := func() error {
+		started = true
+		start.Name = jsonName(key, scope, false)
+		for _, attr := range attrs {
+			start.Attr = append(start.Attr, xml.Attr{Name: jsonName(attr[0], scope, true), Value: attr[1]})
+		}
+		return c.w.EncodeToken(start)
+	}
+
+	if tok != json.Delim('{') {
+		text, err := jsonText(tok)
+		if err != nil {
+			return err
+		}
+		if err := begin(); err != nil {
+			return err
+		}
+		if text != "" {
+			if err := c.w.EncodeToken(xml.CharData(text)); err != nil {
+				return err
+			}
+		}
+		return c.w.EncodeToken(start.End())
+	}
+
+	for c.d.More() {
+		k, err := c.key()
+		if err != nil {
+			return err
+		}
+		if c.conv != Parker && strings.HasPrefix(k, "@") {
+			if started {
+				return fmt.Errorf("xmlstream: JSON attribute %q found after element content", k)
+			}
+			attrs, err = c.attr(k[1:], scope, attrs)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+		tok, err := c.d.Token()
+		if err != nil {
+			return err
+		}
+		if c.conv != Parker && k == c.conv.textKey() {
+			text, err := jsonText(tok)
+			if err != nil {
+				return err
+			}
+			if err := c.w.EncodeToken(xml.CharData(text)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.element(k, tok, scope); err != nil {
+			return err
+		}
+	}
+	if err := c.delim('}'); err != nil {
+		return err
+	}
+	if !started {
+		if err := begin(); err != nil {
+			return err
+		}
+	}
+	return c.w.EncodeToken(start.End())
+}
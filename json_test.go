@@ -0,0 +1,90 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+var jsonTests = [...]struct {
+	conv xmlstream.JSONConvention
+	xml  string
+	json string
+	// The XML expected when converting the JSON back, if different from the
+	// input.
+	back string
+}{
+	0: {conv: xmlstream.BadgerFish},
+	1: {
+		conv: xmlstream.BadgerFish,
+		xml:  `<a xmlns="urn:a" b="c">d &amp; <e/></a>`,
+		json: `{"a":{"@xmlns":{"$":"urn:a"},"@b":"c","e":{},"$":"d & "}}` + "\n",
+		back: `<a xmlns="urn:a" b="c"><e xmlns="urn:a"></e>d &amp; </a>`,
+	},
+	2: {
+		conv: xmlstream.BadgerFish,
+		xml:  `<a xmlns:p="urn:p"><p:b>1</p:b><p:b>2</p:b><c p:d="e"/><b/></a><f/>`,
+		json: `{"a":{"@xmlns":{"p":"urn:p"},"p:b":[{"$":"1"},{"$":"2"}],"c":{"@p:d":"e"},"b":{}}}` + "\n" + `{"f":{}}` + "\n",
+		back: `<a><b xmlns="urn:p">1</b><b xmlns="urn:p">2</b><c xmlns:_="urn:p" _:d="e"></c><b></b></a><f></f>`,
+	},
+	3: {
+		conv: xmlstream.Parker,
+		xml:  `<a b="c"><d>1</d><d/><d><e>2</e></d><f>  </f></a>`,
+		json: `{"d":["1",null,{"e":"2"}],"f":null}` + "\n",
+		back: `<root><d>1</d><d></d><d><e>2</e></d><f></f></root>`,
+	},
+	4: {
+		conv: xmlstream.AttrText,
+		xml:  `<a xmlns="urn:a"><b c="d">e</b><f>g</f></a>`,
+		json: `{"a":{"@xmlns":"urn:a","b":{"@c":"d","#text":"e"},"f":"g"}}` + "\n",
+		back: `<a xmlns="urn:a"><b xmlns="urn:a" c="d">e</b><f xmlns="urn:a">g</f></a>`,
+	},
+}
+
+func TestJSON(t *testing.T) {
+	for i, tc := range jsonTests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var buf strings.Builder
+			w := xmlstream.JSONWriter(&buf, tc.conv)
+			if _, err := xmlstream.Copy(w, xml.NewDecoder(strings.NewReader(tc.xml))); err != nil {
+				t.Fatalf("error converting to JSON: %v", err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("error flushing: %v", err)
+			}
+			if out := buf.String(); out != tc.json {
+				t.Errorf("wrong JSON:\nwant=%s\n got=%s", tc.json, out)
+			}
+
+			r := xmlstream.JSONReader(json.NewDecoder(strings.NewReader(buf.String())), tc.conv, xml.Name{Local: "root"})
+			defer r.Close()
+			back, err := encodeAll(r)
+			if err != nil {
+				t.Fatalf("error converting from JSON: %v", err)
+			}
+			want := tc.back
+			if want == "" {
+				want = tc.xml
+			}
+			if back != want {
+				t.Errorf("wrong XML:\nwant=%s\n got=%s", want, back)
+			}
+		})
+	}
+}
+
+func TestJSONReaderAttrAfterContent(t *testing.T) {
+	r := xmlstream.JSONReader(json.NewDecoder(strings.NewReader(`{"a":{"b":"c","@d":"e"}}`)), xmlstream.AttrText, xml.Name{})
+	defer r.Close()
+	if _, err := xmlstream.ReadAll(r); err == nil {
+		t.Errorf("expected error for attribute after content")
+	}
+}
@@ -12,6 +12,7 @@ package xmlstream
 
 import (
 	"encoding/xml"
+	"errors"
 	"io"
 )
 
@@ -68,30 +69,159 @@ func MultiReader(readers ...xml.TokenReader) xml.TokenReader {
 	return &multiReader{readers: readers}
 }
 
+// MultiWriterOption is used to configure the behavior of a MultiWriter.
+type MultiWriterOption func(*multiWriter)
+
+// ContinueOnError keeps writing to the remaining writers when one of them
+// returns an error.
+// All errors that occur during a write are combined with errors.Join.
+func ContinueOnError() MultiWriterOption {
+	return func(mw *multiWriter) {
+		mw.cont = true
+	}
+}
+
+// DropFailed removes writers that return an error from the MultiWriter so that
+// they are not written to again.
+// It implies ContinueOnError.
+func DropFailed() MultiWriterOption {
+	return func(mw *multiWriter) {
+		mw.cont = true
+		mw.drop = true
+	}
+}
+
+// Concurrent writes to each writer in its own goroutine and waits for all of
+// them to finish before returning.
+// One goroutine is started per writer when the MultiWriter is created and runs
+// until it is closed or the writer is dropped.
+// Because every writer is always written to, it implies ContinueOnError.
+func Concurrent() MultiWriterOption {
+	return func(mw *multiWriter) {
+		mw.cont = true
+		mw.concurrent = true
+	}
+}
+
+var errClosedMultiWriter = errors.New("xmlstream: write to closed MultiWriter")
+
 type multiWriter struct {
-	writers []TokenWriter
+	writers    []TokenWriter
+	workers    []multiWorker
+	cont       bool
+	drop       bool
+	concurrent bool
+	closed     bool
 }
 
-func (mw *multiWriter) EncodeToken(t xml.Token) error {
-	for _, w := range mw.writers {
-		if err := w.EncodeToken(t); err != nil {
-			return err
+// multiWorker calls the functions sent to it with a single writer in its own
+// goroutine.
+type multiWorker struct {
+	jobs chan func(TokenWriter) error
+	errs chan error
+}
+
+func startWorker(w TokenWriter) multiWorker {
+	worker := multiWorker{
+		jobs: make(chan func(TokenWriter) error),
+		errs: make(chan error),
+	}
+	go func() {
+		for f := range worker.jobs {
+			worker.errs <- f(w)
+		}
+	}()
+	return worker
+}
+
+// each calls f for each active writer according to the error policy.
+func (mw *multiWriter) each(f func(w TokenWriter) error) error {
+	if mw.closed {
+		return errClosedMultiWriter
+	}
+	errs := make([]error, len(mw.writers))
+	if mw.workers != nil {
+		for _, worker := range mw.workers {
+			worker.jobs <- f
+		}
+		for i, worker := range mw.workers {
+			errs[i] = <-worker.errs
+		}
+	} else {
+		for i, w := range mw.writers {
+			errs[i] = f(w)
+			if errs[i] != nil && !mw.cont {
+				return errs[i]
+			}
 		}
 	}
-	return nil
+
+	if mw.drop {
+		active := mw.writers[:0:0]
+		var workers []multiWorker
+		for i, w := range mw.writers {
+			switch {
+			case errs[i] == nil:
+				active = append(active, w)
+				if mw.workers != nil {
+					workers = append(workers, mw.workers[i])
+				}
+			case mw.workers != nil:
+				close(mw.workers[i].jobs)
+			}
+		}
+		mw.writers = active
+		if mw.workers != nil {
+			mw.workers = workers
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (mw *multiWriter) EncodeToken(t xml.Token) error {
+	return mw.each(func(w TokenWriter) error {
+		return w.EncodeToken(t)
+	})
 }
 
 func (mw *multiWriter) Flush() error {
-	for _, w := range mw.writers {
+	return mw.each(func(w TokenWriter) error {
 		flusher, ok := w.(Flusher)
 		if !ok {
-			continue
+			return nil
 		}
-		if err := flusher.Flush(); err != nil {
-			return err
+		return flusher.Flush()
+	})
+}
+
+// multiWriteCloser is a multiWriter that can be closed.
+// Only MultiWriterWithOptions returns one, so that the writer returned by
+// MultiWriter does not implement io.Closer.
+type multiWriteCloser struct {
+	*multiWriter
+	all []TokenWriter
+}
+
+// Close stops any goroutines started by the Concurrent option, closes every
+// writer that implements io.Closer, including any that were dropped, and returns
+// all errors combined with errors.Join.
+// Writes after Close return an error.
+func (mw *multiWriteCloser) Close() error {
+	if mw.closed {
+		return nil
+	}
+	mw.closed = true
+	for _, worker := range mw.workers {
+		close(worker.jobs)
+	}
+	mw.workers = nil
+	var errs []error
+	for _, w := range mw.all {
+		if c, ok := w.(io.Closer); ok {
+			errs = append(errs, c.Close())
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 // MultiWriter creates a writer that duplicates its writes to all the
@@ -101,5 +231,28 @@ func (mw *multiWriter) Flush() error {
 func MultiWriter(writers ...TokenWriter) TokenWriter {
 	w := make([]TokenWriter, len(writers))
 	copy(w, writers)
-	return &multiWriter{w}
+	return &multiWriter{writers: w}
+}
+
+// MultiWriterWithOptions is like MultiWriter except that the behavior when a
+// writer returns an error is configurable and the returned writer propagates
+// calls to Flush and Close to any writers that implement Flusher and io.Closer.
+// If no options are provided it stops writing at the first error like
+// MultiWriter.
+// If the Concurrent option is used, Close must be called to stop its
+// goroutines.
+func MultiWriterWithOptions(writers []TokenWriter, opts ...MultiWriterOption) TokenWriteFlushCloser {
+	w := make([]TokenWriter, len(writers))
+	copy(w, writers)
+	mw := &multiWriter{writers: w}
+	for _, opt := range opts {
+		opt(mw)
+	}
+	if mw.concurrent {
+		mw.workers = make([]multiWorker, len(w))
+		for i, w := range w {
+			mw.workers[i] = startWorker(w)
+		}
+	}
+	return &multiWriteCloser{multiWriter: mw, all: w}
 }
@@ -231,6 +231,13 @@ func TestMultiWriter(t *testing.T) {
 		}
 	})
 
+	t.Run("NotCloser", func(t *testing.T) {
+		mw := xmlstream.MultiWriter(&closeWriter{})
+		if _, ok := mw.(io.Closer); ok {
+			t.Errorf("MultiWriter should not implement io.Closer")
+		}
+	})
+
 	t.Run("Error", func(t *testing.T) {
 		b1, b2, b3 := new(bufWriter), &errWriter{errors.New("err")}, new(bufWriter)
 		mw := xmlstream.MultiWriter(b1, b2, b3)
@@ -249,6 +256,108 @@ func TestMultiWriter(t *testing.T) {
 	})
 }
 
+type closeWriter struct {
+	bufWriter
+	closed bool
+	err    error
+}
+
+func (w *closeWriter) Close() error {
+	w.closed = true
+	return w.err
+}
+
+func TestMultiWriterWithOptions(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	tok := xml.CharData("test")
+
+	t.Run("Default", func(t *testing.T) {
+		b1, b2 := &errWriter{errA}, new(bufWriter)
+		mw := xmlstream.MultiWriterWithOptions([]xmlstream.TokenWriter{b1, b2})
+		if err := mw.EncodeToken(tok); err != errA {
+			t.Errorf("wrong error: want=%v, got=%v", errA, err)
+		}
+		if b2.wc != 0 {
+			t.Errorf("expected writing to stop at the first error, got %d writes", b2.wc)
+		}
+	})
+
+	t.Run("ContinueOnError", func(t *testing.T) {
+		b1, b2, b3 := &errWriter{errA}, new(bufWriter), &errWriter{errB}
+		mw := xmlstream.MultiWriterWithOptions([]xmlstream.TokenWriter{b1, b2, b3}, xmlstream.ContinueOnError())
+		for i := 0; i < 2; i++ {
+			err := mw.EncodeToken(tok)
+			if !errors.Is(err, errA) || !errors.Is(err, errB) {
+				t.Errorf("expected both errors to be returned, got %v", err)
+			}
+		}
+		if b2.wc != 2 {
+			t.Errorf("expected 2 writes, got %d", b2.wc)
+		}
+	})
+
+	t.Run("DropFailed", func(t *testing.T) {
+		b1, b2 := &errWriter{errA}, new(bufWriter)
+		mw := xmlstream.MultiWriterWithOptions([]xmlstream.TokenWriter{b1, b2}, xmlstream.DropFailed())
+		if err := mw.EncodeToken(tok); !errors.Is(err, errA) {
+			t.Errorf("wrong error: want=%v, got=%v", errA, err)
+		}
+		if err := mw.EncodeToken(tok); err != nil {
+			t.Errorf("expected failed writer to be dropped, got error %v", err)
+		}
+		if b2.wc != 2 {
+			t.Errorf("expected 2 writes, got %d", b2.wc)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		b1, b2, b3 := new(bufWriter), &errWriter{errA}, new(bufWriter)
+		mw := xmlstream.MultiWriterWithOptions([]xmlstream.TokenWriter{b1, b2, b3}, xmlstream.Concurrent())
+		for i := 0; i < 2; i++ {
+			if err := mw.EncodeToken(tok); !errors.Is(err, errA) {
+				t.Errorf("wrong error: want=%v, got=%v", errA, err)
+			}
+		}
+		if b1.wc != 2 || b3.wc != 2 {
+			t.Errorf("Expected (2,2) writes, got: (%d,%d)", b1.wc, b3.wc)
+		}
+		if err := mw.Close(); err != nil {
+			t.Errorf("error closing: %v", err)
+		}
+		if err := mw.EncodeToken(tok); err == nil {
+			t.Errorf("expected error writing after close")
+		}
+	})
+
+	t.Run("ConcurrentDropFailed", func(t *testing.T) {
+		b1, b2 := &errWriter{errA}, new(bufWriter)
+		mw := xmlstream.MultiWriterWithOptions([]xmlstream.TokenWriter{b1, b2}, xmlstream.Concurrent(), xmlstream.DropFailed())
+		if err := mw.EncodeToken(tok); !errors.Is(err, errA) {
+			t.Errorf("wrong error: want=%v, got=%v", errA, err)
+		}
+		if err := mw.EncodeToken(tok); err != nil {
+			t.Errorf("expected failed writer to be dropped, got error %v", err)
+		}
+		if b2.wc != 2 {
+			t.Errorf("expected 2 writes, got %d", b2.wc)
+		}
+		if err := mw.Close(); err != nil {
+			t.Errorf("error closing: %v", err)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		c1, b2, c3 := &closeWriter{err: errA}, new(bufWriter), &closeWriter{}
+		mw := xmlstream.MultiWriterWithOptions([]xmlstream.TokenWriter{c1, b2, c3})
+		if err := mw.Close(); !errors.Is(err, errA) {
+			t.Errorf("wrong error: want=%v, got=%v", errA, err)
+		}
+		if !c1.closed || !c3.closed {
+			t.Errorf("expected all closers to be closed, got (%t,%t)", c1.closed, c3.closed)
+		}
+	})
+}
+
 func TestNoEOF(t *testing.T) {
 	// Decoders don't return an io.EOF, so we have to check for nil, nil too
 	// otherwise the multireader will terminate early.
@@ -0,0 +1,220 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+)
+
+// Handler responds to an XML element.
+//
+// HandleXML is passed the start element and a reader that is limited to the
+// rest of the element, including its end element.
+// Any part of the element that the handler does not read is skipped.
+type Handler interface {
+	HandleXML(start xml.StartElement, r xml.TokenReader, w TokenWriter) error
+}
+
+// The HandlerFunc type is an adapter to allow the use of ordinary functions as
+// handlers.
+type HandlerFunc func(start xml.StartElement, r xml.TokenReader, w TokenWriter) error
+
+// HandleXML calls f(start, r, w).
+func (f HandlerFunc) HandleXML(start xml.StartElement, r xml.TokenReader, w TokenWriter) error {
+	return f(start, r, w)
+}
+
+// Middleware wraps a Handler to add behavior before or after it is called.
+type Middleware func(Handler) Handler
+
+// Matcher is a condition, in addition to its name, that an element must meet to
+// be dispatched to a handler.
+type Matcher struct {
+	f        func(start xml.StartElement, r xml.TokenReader) bool
+	children bool
+}
+
+// nameMatches reports whether name matches pattern using the same rules as
+// Insert: an empty Space or Local in the pattern matches anything.
+func nameMatches(pattern, name xml.Name) bool {
+	return (pattern.Space == "" || pattern.Space == name.Space) &&
+		(pattern.Local == "" || pattern.Local == name.Local)
+}
+
+// MatchAttr matches elements that have an attribute with the given name and
+// value.
+// The attribute name may contain wildcards as in Insert.
+func MatchAttr(name xml.Name, value string) Matcher {
+	return Matcher{f: func(start xml.StartElement, _ xml.TokenReader) bool {
+		for _, attr := range start.Attr {
+			if nameMatches(name, attr.Name) && attr.Value == value {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// MatchChild matches elements that have a direct child element with the given
+// name.
+// The name may contain wildcards as in Insert.
+// Elements that may be matched by a handler that uses MatchChild are read into
+// memory before being dispatched.
+func MatchChild(name xml.Name) Matcher {
+	return Matcher{
+		children: true,
+		f: func(_ xml.StartElement, r xml.TokenReader) bool {
+			iter := NewIter(r)
+			for iter.Next() {
+				start, _ := iter.Current()
+				if start != nil && nameMatches(name, start.Name) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// MatchFunc matches elements for which f returns true.
+func MatchFunc(f func(start xml.StartElement) bool) Matcher {
+	return Matcher{f: func(start xml.StartElement, _ xml.TokenReader) bool {
+		return f(start)
+	}}
+}
+
+type route struct {
+	name     xml.Name
+	match    []Matcher
+	children bool
+	h        Handler
+}
+
+// specificity ranks routes so that names without wildcards are tried first,
+// followed by routes with more matchers.
+func (r route) specificity() int {
+	n := len(r.match)
+	if r.name.Space != "" {
+		n += 1 << 16
+	}
+	if r.name.Local != "" {
+		n += 1 << 16
+	}
+	return n
+}
+
+// Mux is an XML element multiplexer.
+// It iterates over the children of an element and dispatches each one to the
+// handler registered for the most specific pattern that matches it.
+//
+// Patterns are names which may contain wildcards as in Insert, and optional
+// Matchers that must all match.
+// Routes with fewer wildcards are tried first, then routes with more matchers,
+// and finally routes in the order they were registered.
+// If no route matches, the fallback handler is called if one has been set;
+// otherwise the element is skipped.
+//
+// The zero value for Mux is an empty mux ready to use.
+type Mux struct {
+	routes     []route
+	middleware []Middleware
+	fallback   Handler
+}
+
+// Handle registers the handler for elements with the given name that meet all
+// of the matchers.
+func (m *Mux) Handle(name xml.Name, h Handler, match ...Matcher) {
+	r := route{name: name, match: match, h: h}
+	for _, mm := range match {
+		r.children = r.children || mm.children
+	}
+	i := len(m.routes)
+	for i > 0 && m.routes[i-1].specificity() < r.specificity() {
+		i--
+	}
+	m.routes = append(m.routes, route{})
+	copy(m.routes[i+1:], m.routes[i:])
+	m.routes[i] = r
+}
+
+// HandleFunc registers the handler function for elements with the given name
+// that meet all of the matchers.
+func (m *Mux) HandleFunc(name xml.Name, f func(start xml.StartElement, r xml.TokenReader, w TokenWriter) error, match ...Matcher) {
+	m.Handle(name, HandlerFunc(f), match...)
+}
+
+// Use adds middleware that wraps every handler called by the mux, including
+// the fallback handler.
+// Middleware added first is the outermost.
+func (m *Mux) Use(mw ...Middleware) {
+	m.middleware = append(m.middleware, mw...)
+}
+
+// Fallback sets the handler that is called for elements that do not match any
+// route.
+func (m *Mux) Fallback(h Handler) {
+	m.fallback = h
+}
+
+// HandleXML dispatches each child of the element to a handler.
+// The start element has already been consumed from r, which is read until the
+// end of the element, or until io.EOF if the mux is used at the top level of a
+// stream.
+// If a handler returns an error, HandleXML stops and returns it.
+func (m *Mux) HandleXML(_ xml.StartElement, r xml.TokenReader, w TokenWriter) error {
+	iter := NewIter(r)
+	for iter.Next() {
+		start, child := iter.Current()
+		if start == nil {
+			continue
+		}
+		if err := m.dispatch(*start, child, w); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+func (m *Mux) dispatch(start xml.StartElement, r xml.TokenReader, w TokenWriter) error {
+	var buf []xml.Token
+	buffered := false
+	h := m.fallback
+	for _, rt := range m.routes {
+		if !nameMatches(rt.name, start.Name) {
+			continue
+		}
+		if rt.children && !buffered {
+			var err error
+			buf, err = ReadAll(r)
+			if err != nil {
+				return err
+			}
+			buffered = true
+		}
+		matched := true
+		for _, mm := range rt.match {
+			toks := sliceReader(buf)
+			if !mm.f(start, &toks) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			h = rt.h
+			break
+		}
+	}
+	if h == nil {
+		return nil
+	}
+	for i := len(m.middleware) - 1; i >= 0; i-- {
+		h = m.middleware[i](h)
+	}
+	if buffered {
+		toks := sliceReader(buf)
+		r = &toks
+	}
+	return h.HandleXML(start, r, w)
+}
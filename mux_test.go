@@ -0,0 +1,129 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+// record returns a handler that appends its name to calls and reads part of
+// the element.
+func record(calls *[]string, name string) xmlstream.HandlerFunc {
+	return func(start xml.StartElement, r xml.TokenReader, w xmlstream.TokenWriter) error {
+		*calls = append(*calls, name+":"+start.Name.Local)
+		_, err := r.Token()
+		return err
+	}
+}
+
+func TestMux(t *testing.T) {
+	const in = `<stream xmlns="jabber:client">
+<iq type="get" id="1"><query xmlns="jabber:iq:roster"/></iq>
+<iq type="set" id="2"><query xmlns="jabber:iq:roster"><item/></query></iq>
+<iq type="get" id="3"><ping xmlns="urn:xmpp:ping"/></iq>
+<message><body>hi</body><body>there</body></message>
+<presence/>
+<other xmlns="urn:other"/>
+</stream>`
+
+	var calls []string
+	var m xmlstream.Mux
+	m.Handle(xml.Name{Local: "iq"}, record(&calls, "iq"))
+	m.Handle(xml.Name{Space: "jabber:client", Local: "iq"}, record(&calls, "roster-get"),
+		xmlstream.MatchAttr(xml.Name{Local: "type"}, "get"),
+		xmlstream.MatchChild(xml.Name{Space: "jabber:iq:roster", Local: "query"}),
+	)
+	m.HandleFunc(xml.Name{Space: "jabber:client"}, record(&calls, "client"))
+	// Has the same specificity as the previous route, so it is never used.
+	m.HandleFunc(xml.Name{Local: "message"}, record(&calls, "message"))
+	m.Fallback(record(&calls, "fallback"))
+	m.Use(func(h xmlstream.Handler) xmlstream.Handler {
+		return xmlstream.HandlerFunc(func(start xml.StartElement, r xml.TokenReader, w xmlstream.TokenWriter) error {
+			calls = append(calls, "mw1")
+			return h.HandleXML(start, r, w)
+		})
+	}, func(h xmlstream.Handler) xmlstream.Handler {
+		return xmlstream.HandlerFunc(func(start xml.StartElement, r xml.TokenReader, w xmlstream.TokenWriter) error {
+			calls = append(calls, "mw2")
+			return h.HandleXML(start, r, w)
+		})
+	})
+
+	d := xml.NewDecoder(strings.NewReader(in))
+	tok, err := d.Token()
+	if err != nil {
+		t.Fatalf("error reading start: %v", err)
+	}
+	if err := m.HandleXML(tok.(xml.StartElement), d, xmlstream.Discard()); err != nil {
+		t.Fatalf("error handling stream: %v", err)
+	}
+
+	want := []string{
+		"mw1", "mw2", "roster-get:iq",
+		"mw1", "mw2", "iq:iq",
+		"mw1", "mw2", "iq:iq",
+		"mw1", "mw2", "client:message",
+		"mw1", "mw2", "client:presence",
+		"mw1", "mw2", "fallback:other",
+	}
+	if got := strings.Join(calls, " "); got != strings.Join(want, " ") {
+		t.Errorf("wrong calls:\nwant=%v,\n got=%v", want, calls)
+	}
+}
+
+func TestMuxBufferedReader(t *testing.T) {
+	var m xmlstream.Mux
+	var body string
+	m.HandleFunc(xml.Name{Local: "message"}, func(start xml.StartElement, r xml.TokenReader, w xmlstream.TokenWriter) error {
+		toks, err := xmlstream.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		var buf strings.Builder
+		for _, tok := range toks {
+			switch tok := tok.(type) {
+			case xml.StartElement:
+				buf.WriteString("<" + tok.Name.Local + ">")
+			case xml.EndElement:
+				buf.WriteString("</" + tok.Name.Local + ">")
+			case xml.CharData:
+				buf.Write(tok)
+			}
+		}
+		body = buf.String()
+		return nil
+	}, xmlstream.MatchChild(xml.Name{Local: "body"}))
+
+	d := xml.NewDecoder(strings.NewReader(`<message><body>hi</body></message><message><subject/></message>`))
+	if err := m.HandleXML(xml.StartElement{}, d, xmlstream.Discard()); err != nil {
+		t.Fatalf("error handling: %v", err)
+	}
+	const want = `<body>hi</body></message>`
+	if body != want {
+		t.Errorf("wrong body:\nwant=%s,\n got=%s", want, body)
+	}
+}
+
+func TestMuxError(t *testing.T) {
+	errTest := errors.New("test")
+	var m xmlstream.Mux
+	calls := 0
+	m.HandleFunc(xml.Name{}, func(xml.StartElement, xml.TokenReader, xmlstream.TokenWriter) error {
+		calls++
+		return errTest
+	})
+	d := xml.NewDecoder(strings.NewReader(`<a/><b/>`))
+	if err := m.HandleXML(xml.StartElement{}, d, xmlstream.Discard()); err != errTest {
+		t.Errorf("wrong error: want=%v, got=%v", errTest, err)
+	}
+	if calls != 1 {
+		t.Errorf("expected handling to stop after the first error, got %d calls", calls)
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+	"strconv"
+)
+
+// nsBinding binds a prefix to a namespace URI.
+// An empty prefix is the default namespace.
+type nsBinding struct {
+	prefix, uri string
+}
+
+// nsScope tracks the namespace bindings that are in scope.
+// Bindings made by an element are removed by resetting the scope to the mark
+// taken before the element's bindings were added.
+type nsScope struct {
+	bindings []nsBinding
+}
+
+func (s *nsScope) bind(prefix, uri string) {
+	s.bindings = append(s.bindings, nsBinding{prefix: prefix, uri: uri})
+}
+
+func (s *nsScope) mark() int {
+	return len(s.bindings)
+}
+
+func (s *nsScope) reset(mark int) {
+	s.bindings = s.bindings[:mark]
+}
+
+// uri returns the URI bound to prefix.
+func (s *nsScope) uri(prefix string) (string, bool) {
+	for i := len(s.bindings) - 1; i >= 0; i-- {
+		if s.bindings[i].prefix == prefix {
+			return s.bindings[i].uri, true
+		}
+	}
+	return "", false
+}
+
+// prefix returns a non-empty prefix that is bound to uri and not shadowed.
+func (s *nsScope) prefix(uri string) (string, bool) {
+	for i := len(s.bindings) - 1; i >= 0; i-- {
+		b := s.bindings[i]
+		if b.prefix == "" || b.uri != uri {
+			continue
+		}
+		if u, _ := s.uri(b.prefix); u == uri {
+			return b.prefix, true
+		}
+	}
+	return "", false
+}
+
+// NSOption configures the RewriteNS transformer.
+type NSOption func(*nsRewriter)
+
+// NSRaw indicates that the Space field of names contains a prefix instead of a
+// namespace URI, as in tokens returned by the RawToken method of xml.Decoder.
+// The transformed tokens will also be in this form.
+func NSRaw() NSOption {
+	return func(n *nsRewriter) {
+		n.raw = true
+	}
+}
+
+// NSPrefix sets the preferred prefix for a namespace URI.
+// Elements in the namespace are always written with the prefix instead of
+// using a default namespace declaration.
+func NSPrefix(uri, prefix string) NSOption {
+	return func(n *nsRewriter) {
+		n.assign(uri, prefix)
+		n.preferred[uri] = true
+	}
+}
+
+// NSHoist declares the namespaces on every top level element so that
+// declarations of the same namespaces further down the tree can be removed.
+// The namespaces use their preferred prefix if one was set with NSPrefix, or a
+// generated prefix otherwise.
+func NSHoist(uri ...string) NSOption {
+	return func(n *nsRewriter) {
+		n.hoist = append(n.hoist, uri...)
+	}
+}
+
+// RewriteNS returns a transformer that rewrites namespace prefixes and
+// declarations.
+//
+// Each namespace URI is given a single prefix that is used everywhere in the
+// stream: the preferred prefix set with NSPrefix, the first prefix the
+// namespace was declared with if it does not conflict with another namespace,
+// or a generated prefix.
+// Namespace declarations that are already in scope are removed, and
+// declarations are added for any prefixes that are used but not declared.
+// Elements that were written without a prefix keep using a default namespace
+// declaration unless a preferred prefix was set for their namespace.
+//
+// By default tokens are expected to be decoded, as in those returned by the
+// Token method of xml.Decoder.
+// Names keep their namespace URI and only the namespace declarations in the
+// attributes are changed, so the output should be encoded with an encoder that
+// picks prefixes from the declarations, such as XMLWriter.
+// To work on raw tokens use NSRaw.
+func RewriteNS(opts ...NSOption) Transformer {
+	return func(r xml.TokenReader) xml.TokenReader {
+		n := &nsRewriter{
+			r:         r,
+			prefixes:  make(map[string]string),
+			assigned:  make(map[string]string),
+			preferred: make(map[string]bool),
+		}
+		n.assigned["xml"] = xmlNS
+		n.prefixes[xmlNS] = "xml"
+		for _, opt := range opts {
+			opt(n)
+		}
+		return n
+	}
+}
+
+// nsElem is an element that has been started but not ended.
+type nsElem struct {
+	name    xml.Name
+	in, out int
+}
+
+type nsRewriter struct {
+	r         xml.TokenReader
+	raw       bool
+	hoist     []string
+	prefixes  map[string]string
+	assigned  map[string]string
+	preferred map[string]bool
+	gen       int
+	in, out   nsScope
+	stack     []nsElem
+}
+
+// assign returns the prefix for uri, assigning one if the namespace has not
+// been seen before.
+// hint is used if it has not already been assigned to another namespace.
+func (n *nsRewriter) assign(uri, hint string) string {
+	if p, ok := n.prefixes[uri]; ok {
+		return p
+	}
+	if _, taken := n.assigned[hint]; hint == "" || hint == "xmlns" || taken {
+		for {
+			n.gen++
+			hint = "ns" + strconv.Itoa(n.gen)
+			if _, taken := n.assigned[hint]; !taken {
+				break
+			}
+		}
+	}
+	n.prefixes[uri] = hint
+	n.assigned[hint] = uri
+	return hint
+}
+
+func (n *nsRewriter) Token() (xml.Token, error) {
+	tok, err := n.r.Token()
+	switch t := tok.(type) {
+	case xml.StartElement:
+		tok = n.start(t)
+	case xml.EndElement:
+		if len(n.stack) > 0 {
+			el := n.stack[len(n.stack)-1]
+			n.stack = n.stack[:len(n.stack)-1]
+			n.in.reset(el.in)
+			n.out.reset(el.out)
+			tok = xml.EndElement{Name: el.name}
+		}
+	}
+	return tok, err
+}
+
+func isDecl(attr xml.Attr) bool {
+	return attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns")
+}
+
+// resolve returns the namespace of a name in the input and the prefix it was
+// written with.
+// If the prefix of a raw name is not bound, ok is false.
+func (n *nsRewriter) resolve(name xml.Name, elem bool) (uri, prefix string, ok bool) {
+	if !n.raw {
+		if def, _ := n.in.uri(""); name.Space != def || !elem {
+			prefix, _ = n.in.prefix(name.Space)
+		}
+		return name.Space, prefix, true
+	}
+	switch name.Space {
+	case "":
+		if !elem {
+			return "", "", true
+		}
+		uri, _ = n.in.uri("")
+		return uri, "", true
+	case "xml":
+		return xmlNS, "xml", true
+	}
+	uri, ok = n.in.uri(name.Space)
+	return uri, name.Space, ok
+}
+
+func (n *nsRewriter) start(start xml.StartElement) xml.StartElement {
+	el := nsElem{in: n.in.mark(), out: n.out.mark()}
+	var decls []xml.Attr
+	declare := func(prefix, uri string) {
+		n.out.bind(prefix, uri)
+		if prefix == "" {
+			decls = append(decls, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: uri})
+			return
+		}
+		decls = append(decls, xml.Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: uri})
+	}
+	// use returns the prefix for uri and declares it if it is not in scope.
+	use := func(uri, hint string) string {
+		p := n.assign(uri, hint)
+		if u, ok := n.out.uri(p); p != "xml" && (!ok || u != uri) {
+			declare(p, uri)
+		}
+		return p
+	}
+
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Space == "xmlns":
+			n.in.bind(attr.Name.Local, attr.Value)
+		case isDecl(attr):
+			n.in.bind("", attr.Value)
+		}
+	}
+
+	if len(n.stack) == 0 {
+		for _, uri := range n.hoist {
+			use(uri, "")
+		}
+	}
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Space == "xmlns":
+			use(attr.Value, attr.Name.Local)
+		case isDecl(attr):
+			// Namespaces with a preferred prefix never use the default namespace.
+			if n.preferred[attr.Value] {
+				break
+			}
+			if def, _ := n.out.uri(""); def != attr.Value {
+				declare("", attr.Value)
+			}
+		}
+	}
+
+	name := start.Name
+	uri, hint, ok := n.resolve(name, true)
+	if ok {
+		var prefix string
+		def, _ := n.out.uri("")
+		switch {
+		case uri == def:
+		case uri != "" && (hint != "" || n.preferred[uri]):
+			prefix = use(uri, hint)
+		default:
+			declare("", uri)
+		}
+		if n.raw {
+			name.Space = prefix
+		}
+	}
+
+	attrs := make([]xml.Attr, 0, len(start.Attr))
+	for _, attr := range start.Attr {
+		if isDecl(attr) {
+			continue
+		}
+		uri, hint, ok := n.resolve(attr.Name, false)
+		if ok && uri != "" {
+			p := use(uri, hint)
+			if n.raw {
+				attr.Name.Space = p
+			}
+		}
+		attrs = append(attrs, attr)
+	}
+
+	el.name = name
+	n.stack = append(n.stack, el)
+	return xml.StartElement{Name: name, Attr: append(decls, attrs...)}
+}
@@ -0,0 +1,125 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+var rewriteNSTestCases = [...]struct {
+	opts []xmlstream.NSOption
+	raw  bool
+	in   string
+	out  string
+}{
+	0: {},
+	1: {
+		raw: true,
+		in:  `<stream xmlns="jabber:client" xmlns:s="urn:s"><s:x xmlns:s="urn:s"/><message xmlns="jabber:client"/></stream>`,
+		out: `<stream xmlns="jabber:client" xmlns:s="urn:s"><s:x></s:x><message></message></stream>`,
+	},
+	2: {
+		in:  `<stream xmlns="jabber:client" xmlns:s="urn:s"><s:x xmlns:s="urn:s"/><message xmlns="jabber:client"/></stream>`,
+		out: `<stream xmlns="jabber:client" xmlns:s="urn:s"><s:x></s:x><message></message></stream>`,
+	},
+	3: {
+		raw: true,
+		in:  `<a xmlns:p="urn:1"><p:b/><c xmlns:p="urn:2"><p:d p:e="f"/></c><p:b/></a>`,
+		out: `<a xmlns:p="urn:1"><p:b></p:b><c xmlns:ns1="urn:2"><ns1:d ns1:e="f"></ns1:d></c><p:b></p:b></a>`,
+	},
+	4: {
+		opts: []xmlstream.NSOption{xmlstream.NSPrefix("urn:s", "stream")},
+		raw:  true,
+		in:   `<s:stream xmlns:s="urn:s" xmlns="jabber:client"><message/></s:stream>`,
+		out:  `<stream:stream xmlns:stream="urn:s" xmlns="jabber:client"><message></message></stream:stream>`,
+	},
+	5: {
+		opts: []xmlstream.NSOption{xmlstream.NSPrefix("urn:s", "stream")},
+		in:   `<stream xmlns="urn:s"><message xmlns="jabber:client"/></stream>`,
+		out:  `<stream:stream xmlns:stream="urn:s"><message xmlns="jabber:client"></message></stream:stream>`,
+	},
+	6: {
+		opts: []xmlstream.NSOption{xmlstream.NSHoist("urn:x"), xmlstream.NSPrefix("urn:x", "x")},
+		raw:  true,
+		in:   `<a><b xmlns:x="urn:x" x:y="1"/><c xmlns:y="urn:x" y:y="2"/></a>`,
+		out:  `<a xmlns:x="urn:x"><b x:y="1"></b><c x:y="2"></c></a>`,
+	},
+	7: {
+		opts: []xmlstream.NSOption{xmlstream.NSHoist("urn:x")},
+		in:   `<a><b xmlns:x="urn:x" x:y="1"/></a><a/>`,
+		out:  `<a xmlns:ns1="urn:x"><b ns1:y="1"></b></a><a xmlns:ns1="urn:x"></a>`,
+	},
+	8: {
+		in:  `<a xmlns="urn:a"><b xmlns="urn:b"/><a xmlns="urn:a"/><c xmlns=""/></a>`,
+		out: `<a xmlns="urn:a"><b xmlns="urn:b"></b><a></a><c xmlns=""></c></a>`,
+	},
+	9: {
+		raw: true,
+		in:  `<a xml:lang="en"><p:b xmlns:p="urn:p"/></a>`,
+		out: `<a xml:lang="en"><p:b xmlns:p="urn:p"></p:b></a>`,
+	},
+}
+
+func TestRewriteNS(t *testing.T) {
+	for i, tc := range rewriteNSTestCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var r xml.TokenReader = xml.NewDecoder(strings.NewReader(tc.in))
+			var opts []xmlstream.XMLWriterOption
+			nsOpts := tc.opts
+			if tc.raw {
+				r = rawReader{xml.NewDecoder(strings.NewReader(tc.in))}
+				opts = append(opts, xmlstream.RawNames())
+				nsOpts = append(nsOpts, xmlstream.NSRaw())
+			}
+			r = xmlstream.RewriteNS(nsOpts...)(r)
+
+			var buf strings.Builder
+			w := xmlstream.XMLWriter(&buf, opts...)
+			if _, err := xmlstream.Copy(w, r); err != nil {
+				t.Fatalf("error encoding: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("error closing: %v", err)
+			}
+			if out := buf.String(); out != tc.out {
+				t.Errorf("wrong output:\nwant=%s,\n got=%s", tc.out, out)
+			}
+		})
+	}
+}
+
+func TestRewriteNSMultiReader(t *testing.T) {
+	// Splicing a payload with its own declarations into a stream should not
+	// leave redundant declarations behind.
+	start := xml.StartElement{
+		Name: xml.Name{Space: "jabber:client", Local: "message"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: "jabber:client"}},
+	}
+	body := xml.StartElement{
+		Name: xml.Name{Space: "jabber:client", Local: "body"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: "jabber:client"}},
+	}
+	r := xmlstream.RewriteNS()(xmlstream.Wrap(
+		xmlstream.Wrap(xmlstream.Token(xml.CharData("hi")), body),
+		start,
+	))
+	var buf strings.Builder
+	w := xmlstream.XMLWriter(&buf)
+	if _, err := xmlstream.Copy(w, r); err != nil {
+		t.Fatalf("error encoding: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("error closing: %v", err)
+	}
+	const want = `<message xmlns="jabber:client"><body>hi</body></message>`
+	if out := buf.String(); out != want {
+		t.Errorf("wrong output:\nwant=%s,\n got=%s", want, out)
+	}
+}
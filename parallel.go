@@ -0,0 +1,166 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+	"io"
+	"sync"
+)
+
+// sliceWriter is a TokenWriter that appends copies of tokens to a slice.
+type sliceWriter []xml.Token
+
+func (w *sliceWriter) EncodeToken(t xml.Token) error {
+	*w = append(*w, xml.CopyToken(t))
+	return nil
+}
+
+type parallelJob struct {
+	start *xml.StartElement
+	toks  []xml.Token
+	out   sliceWriter
+	err   error
+	done  chan struct{}
+}
+
+// ParallelMap processes the children of the most recent start element already
+// consumed from r concurrently and returns a reader over the results in the
+// original order.
+//
+// Each child element is buffered and passed to f by one of workers goroutines
+// along with a reader over the remainder of the child (like the values returned
+// by Iter's Current method).
+// The tokens written to w become the output for that child.
+// Children that are not elements, such as character data, are passed through
+// unchanged.
+// At most about twice as many children as there are workers are buffered at
+// any one time.
+//
+// If f returns an error, reading from the returned reader returns that error
+// once the output of all preceding children has been read.
+// The returned reader must be closed if it is not read to the end.
+func ParallelMap(r xml.TokenReader, workers int, f func(start xml.StartElement, child xml.TokenReader, w TokenWriter) error) TokenReadCloser {
+	if workers < 1 {
+		workers = 1
+	}
+	return &parallelMapper{
+		r:       r,
+		f:       f,
+		workers: workers,
+		quit:    make(chan struct{}),
+	}
+}
+
+type parallelMapper struct {
+	r       xml.TokenReader
+	f       func(xml.StartElement, xml.TokenReader, TokenWriter) error
+	workers int
+	started bool
+	jobs    chan *parallelJob
+	quit    chan struct{}
+	once    sync.Once
+	out     []xml.Token
+	err     error
+}
+
+func (p *parallelMapper) start() {
+	p.started = true
+	p.jobs = make(chan *parallelJob, p.workers)
+	work := make(chan *parallelJob)
+	for i := 0; i < p.workers; i++ {
+		go func() {
+			for job := range work {
+				toks := sliceReader(job.toks)
+				job.err = p.f(*job.start, &toks, &job.out)
+				job.toks = nil
+				close(job.done)
+			}
+		}()
+	}
+	go p.produce(work)
+}
+
+// produce splits the input into children and queues them in order.
+func (p *parallelMapper) produce(work chan<- *parallelJob) {
+	defer close(p.jobs)
+	defer close(work)
+
+	iter := NewIter(p.r)
+	for iter.Next() {
+		start, child := iter.Current()
+		toks, err := ReadAll(child)
+		job := &parallelJob{toks: toks, err: err, done: make(chan struct{})}
+		if start != nil {
+			s := start.Copy()
+			job.start = &s
+		}
+
+		select {
+		case p.jobs <- job:
+		case <-p.quit:
+			return
+		}
+		if job.start == nil || job.err != nil {
+			job.out = job.toks
+			close(job.done)
+			if job.err != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case work <- job:
+		case <-p.quit:
+			return
+		}
+	}
+	if err := iter.Err(); err != nil {
+		job := &parallelJob{err: err, done: make(chan struct{})}
+		close(job.done)
+		select {
+		case p.jobs <- job:
+		case <-p.quit:
+		}
+	}
+}
+
+func (p *parallelMapper) Token() (xml.Token, error) {
+	if !p.started {
+		p.start()
+	}
+	for {
+		if len(p.out) > 0 {
+			tok := p.out[0]
+			p.out = p.out[1:]
+			return tok, nil
+		}
+		if p.err != nil {
+			return nil, p.err
+		}
+		job, ok := <-p.jobs
+		if !ok {
+			p.err = io.EOF
+			continue
+		}
+		<-job.done
+		if job.err != nil {
+			p.err = job.err
+			p.Close()
+			continue
+		}
+		p.out = job.out
+	}
+}
+
+// Close stops processing children.
+// It does not close the underlying reader.
+func (p *parallelMapper) Close() error {
+	p.once.Do(func() {
+		close(p.quit)
+	})
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mellium.im/xmlstream"
+)
+
+func TestParallelMap(t *testing.T) {
+	const items = 100
+	var in strings.Builder
+	var want strings.Builder
+	in.WriteString("<feed>")
+	for i := 0; i < items; i++ {
+		fmt.Fprintf(&in, "<item>%d</item> ", i)
+		fmt.Fprintf(&want, "<double>%d</double> ", 2*i)
+	}
+	in.WriteString("</feed>")
+
+	d := xml.NewDecoder(strings.NewReader(in.String()))
+	if _, err := d.Token(); err != nil {
+		t.Fatalf("error popping start token: %v", err)
+	}
+	r := xmlstream.ParallelMap(d, 8, func(start xml.StartElement, child xml.TokenReader, w xmlstream.TokenWriter) error {
+		// Make sure children finish out of order.
+		time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+		toks, err := xmlstream.ReadAll(child)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(string(toks[0].(xml.CharData)))
+		if err != nil {
+			return err
+		}
+		_, err = xmlstream.Copy(w, xmlstream.Wrap(
+			xmlstream.Token(xml.CharData(strconv.Itoa(2*n))),
+			xml.StartElement{Name: xml.Name{Local: "double"}},
+		))
+		return err
+	})
+	defer r.Close()
+
+	out, err := encodeAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != want.String() {
+		t.Errorf("wrong output:\nwant=%s,\n got=%s", want.String(), out)
+	}
+	if tok, err := d.Token(); tok != nil || err == nil {
+		t.Errorf("expected input to be consumed, got %v, %v", tok, err)
+	}
+}
+
+func TestParallelMapError(t *testing.T) {
+	errTest := errors.New("test")
+	d := xml.NewDecoder(strings.NewReader(`<feed><a/><b/><c/></feed>`))
+	if _, err := d.Token(); err != nil {
+		t.Fatalf("error popping start token: %v", err)
+	}
+	r := xmlstream.ParallelMap(d, 2, func(start xml.StartElement, child xml.TokenReader, w xmlstream.TokenWriter) error {
+		if start.Name.Local == "b" {
+			return errTest
+		}
+		_, err := xmlstream.Copy(w, xmlstream.MultiReader(xmlstream.Token(start), child))
+		return err
+	})
+	defer r.Close()
+
+	toks, err := xmlstream.ReadAll(r)
+	if err != errTest {
+		t.Errorf("wrong error: want=%v, got=%v", errTest, err)
+	}
+	if len(toks) != 2 {
+		t.Errorf("expected the output of the first child, got %v", toks)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// sliceReader is an xml.TokenReader that returns tokens from a slice.
+type sliceReader []xml.Token
+
+func (r *sliceReader) Token() (xml.Token, error) {
+	if len(*r) == 0 {
+		return nil, io.EOF
+	}
+	tok := (*r)[0]
+	*r = (*r)[1:]
+	return tok, nil
+}
+
+// RawReader decodes elements from a byte stream and records the exact bytes
+// that each element was decoded from.
+// This allows elements to be inspected as tokens and then signed, logged, or
+// forwarded verbatim without re-encoding them.
+//
+// Successive calls to the Next method step through each selected element.
+// Tokens that are not part of a selected element are discarded.
+type RawReader struct {
+	rec   *byteRecorder
+	d     *xml.Decoder
+	match func(start xml.StartElement) bool
+	depth int
+	err   error
+	off   int64
+	start xml.StartElement
+	toks  []xml.Token
+	raw   []byte
+}
+
+// NewRawReader returns a RawReader that decodes elements from r.
+// If match is nil, every top level element is selected.
+// Otherwise every element for which match returns true is selected, no matter
+// its depth; the children of a selected element are not matched separately.
+//
+// The raw bytes of an element do not include namespace declarations made on
+// its ancestors, but its tokens are decoded with them in scope.
+// If r does not implement io.ByteReader it is buffered and the RawReader may
+// read more data from r than it returns.
+func NewRawReader(r io.Reader, match func(start xml.StartElement) bool) *RawReader {
+	rec := newByteRecorder(r)
+	return &RawReader{
+		rec:   rec,
+		d:     xml.NewDecoder(rec),
+		match: match,
+	}
+}
+
+// Next returns true if another element was selected.
+func (r *RawReader) Next() bool {
+	r.toks = nil
+	r.raw = nil
+	if r.err != nil {
+		return false
+	}
+
+	for {
+		off := r.d.InputOffset()
+		tok, err := r.d.Token()
+		if err != nil {
+			r.err = err
+			return false
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if (r.match == nil && r.depth == 0) || (r.match != nil && r.match(t)) {
+				return r.capture(t.Copy(), off)
+			}
+			r.depth++
+		case xml.EndElement:
+			r.depth--
+		}
+		r.rec.drop(r.d.InputOffset())
+	}
+}
+
+func (r *RawReader) capture(start xml.StartElement, off int64) bool {
+	r.rec.drop(off)
+	toks := []xml.Token{start}
+	depth := 0
+	for {
+		tok, err := r.d.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			r.err = err
+			return false
+		}
+		toks = append(toks, xml.CopyToken(tok))
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+			continue
+		case xml.EndElement:
+			depth--
+		}
+		if depth < 0 {
+			break
+		}
+	}
+
+	end := r.d.InputOffset()
+	r.off = off
+	r.start = start
+	r.toks = toks
+	r.raw = append([]byte(nil), r.rec.bytes(off, end)...)
+	r.rec.drop(end)
+	return true
+}
+
+// Current returns the start element of the most recently selected element, a
+// reader over all of its tokens (including the start and end elements), and the
+// bytes it was decoded from.
+// The returned byte slice is not reused by subsequent calls to Next.
+func (r *RawReader) Current() (xml.StartElement, xml.TokenReader, []byte) {
+	toks := sliceReader(r.toks)
+	return r.start, &toks, r.raw
+}
+
+// Offset returns the offset of the most recently selected element relative to
+// the first byte read from the underlying reader.
+func (r *RawReader) Offset() int64 {
+	return r.off
+}
+
+// Err returns the last error encountered by the reader (if any).
+func (r *RawReader) Err() error {
+	if r.err == io.EOF {
+		return nil
+	}
+	return r.err
+}
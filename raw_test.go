@@ -0,0 +1,80 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+var rawReaderTests = [...]struct {
+	in    string
+	match func(xml.StartElement) bool
+	raw   []string
+	off   []int64
+	err   bool
+}{
+	0: {},
+	1: {
+		in:  `<a  x='1'>one<b/></a >  <!-- c --><c xmlns="urn:c">&amp;</c>`,
+		raw: []string{`<a  x='1'>one<b/></a >`, `<c xmlns="urn:c">&amp;</c>`},
+		off: []int64{0, 34},
+	},
+	2: {
+		in: `<stream xmlns="jabber:client"><message><body>hi</body></message><iq/><message/></stream>`,
+		match: func(start xml.StartElement) bool {
+			return start.Name == xml.Name{Space: "jabber:client", Local: "message"}
+		},
+		raw: []string{`<message><body>hi</body></message>`, `<message/>`},
+		off: []int64{30, 69},
+	},
+	3: {
+		in:  `<a><b>`,
+		err: true,
+	},
+}
+
+func TestRawReader(t *testing.T) {
+	for i, tc := range rawReaderTests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r := xmlstream.NewRawReader(strings.NewReader(tc.in), tc.match)
+			var raw []string
+			var off []int64
+			for r.Next() {
+				start, tr, b := r.Current()
+				toks, err := xmlstream.ReadAll(tr)
+				if err != nil {
+					t.Fatalf("error reading tokens: %v", err)
+				}
+				if !reflect.DeepEqual(toks[0], start) {
+					t.Errorf("first token was not the start element: want=%v, got=%v", start, toks[0])
+				}
+				end, ok := toks[len(toks)-1].(xml.EndElement)
+				if !ok || end.Name != start.Name {
+					t.Errorf("last token was not the end element: got=%v", toks[len(toks)-1])
+				}
+				raw = append(raw, string(b))
+				off = append(off, r.Offset())
+			}
+			switch err := r.Err(); {
+			case err != nil && !tc.err:
+				t.Fatalf("unexpected error: %v", err)
+			case err == nil && tc.err:
+				t.Fatalf("expected error, got none")
+			}
+			if !reflect.DeepEqual(raw, tc.raw) {
+				t.Errorf("wrong raw output:\nwant=%q,\n got=%q", tc.raw, raw)
+			}
+			if !reflect.DeepEqual(off, tc.off) {
+				t.Errorf("wrong offsets: want=%v, got=%v", tc.off, off)
+			}
+		})
+	}
+}
@@ -42,15 +42,17 @@ func NopCloser(r xml.TokenReader) TokenReadCloser {
 	return nopCloser{r}
 }
 
-// TODO: We almost certainly need to expose the start token somehow, but I can't
-//       think of a clean API to do it.
-
 // InnerReader is an io.Reader which attempts to decode an xml.StartElement from
 // the stream on the first call to Read (returning an error if an invalid start
 // token is found) and returns a new reader which only reads the inner XML
 // without parsing it or checking its validity.
 // After the inner XML is read, the end token is parsed and if it does not exist
 // or does not match the original start token an error is returned.
+//
+// InnerReader assumes that the entire element fits in its internal buffer and
+// that the end element contains no whitespace.
+// For a reader without these limitations that also exposes the start element,
+// see InnerXMLReader.
 func InnerReader(r io.Reader) io.Reader {
 	var end xml.EndElement
 
@@ -110,3 +112,191 @@ func InnerReader(r io.Reader) io.Reader {
 
 	return before
 }
+
+// byteRecorder is an io.ByteReader that keeps a copy of every byte read through
+// it until the bytes are explicitly dropped.
+// Because xml.Decoder does not add its own buffering when given an
+// io.ByteReader, the recorded bytes line up exactly with the decoder's input
+// offset.
+type byteRecorder struct {
+	r   io.ByteReader
+	buf []byte
+	off int64
+}
+
+func newByteRecorder(r io.Reader) *byteRecorder {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &byteRecorder{r: br}
+}
+
+func (b *byteRecorder) ReadByte() (byte, error) {
+	c, err := b.r.ReadByte()
+	if err == nil {
+		b.buf = append(b.buf, c)
+	}
+	return c, err
+}
+
+func (b *byteRecorder) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		p[n], err = b.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// bytes returns the recorded bytes between the absolute offsets from and to.
+func (b *byteRecorder) bytes(from, to int64) []byte {
+	return b.buf[from-b.off : to-b.off]
+}
+
+// drop discards recorded bytes before the absolute offset off.
+func (b *byteRecorder) drop(off int64) {
+	n := off - b.off
+	if n <= 0 {
+		return
+	}
+	b.buf = b.buf[n:]
+	b.off = off
+	if len(b.buf) == 0 {
+		b.buf = b.buf[:0:0]
+	}
+}
+
+// InnerXMLReader is an io.Reader that reads the inner XML of a single element
+// verbatim.
+// Unlike InnerReader it tokenizes the inner XML as it is read so that it can
+// find the matching end element regardless of namespace prefixes or whitespace
+// in the end tag, and it does not need to buffer the entire element.
+type InnerXMLReader struct {
+	rec      *byteRecorder
+	d        *xml.Decoder
+	start    xml.StartElement
+	hasStart bool
+	began    bool
+	depth    int
+	begin    int64
+	end      int64
+	safe     int64
+	err      error
+}
+
+// NewInnerXMLReader returns a reader that decodes an xml.StartElement from r on
+// the first call to Start or Read (returning an error if anything other than
+// whitespace is found before the start element) and then reads the inner XML of
+// that element without re-encoding it.
+// After the inner XML is read, Read returns io.EOF if the end element matched
+// the start element, or the decoder's syntax error otherwise.
+//
+// If r does not implement io.ByteReader it is buffered and the reader may read
+// more data from r than the element contains.
+func NewInnerXMLReader(r io.Reader) *InnerXMLReader {
+	rec := newByteRecorder(r)
+	return &InnerXMLReader{
+		rec: rec,
+		d:   xml.NewDecoder(rec),
+		end: -1,
+	}
+}
+
+// Start returns the start element of the element being read, decoding it first
+// if necessary.
+func (r *InnerXMLReader) Start() (xml.StartElement, error) {
+	if !r.began {
+		r.began = true
+		r.readStart()
+	}
+	if !r.hasStart {
+		return xml.StartElement{}, r.err
+	}
+	return r.start, nil
+}
+
+// Offset returns the byte offsets of the inner XML relative to the first byte
+// read from the underlying reader.
+// Before the start element has been decoded begin is -1, and until the end
+// element has been found end is -1.
+func (r *InnerXMLReader) Offset() (begin, end int64) {
+	if !r.hasStart {
+		return -1, -1
+	}
+	return r.begin, r.end
+}
+
+func (r *InnerXMLReader) readStart() {
+	for {
+		tok, err := r.d.Token()
+		if err != nil {
+			r.err = err
+			return
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			if isWhitespace(t) {
+				continue
+			}
+		case xml.StartElement:
+			r.start = t.Copy()
+			r.hasStart = true
+			r.begin = r.d.InputOffset()
+			r.safe = r.begin
+			r.rec.drop(r.begin)
+			return
+		}
+		r.err = errNotStart
+		return
+	}
+}
+
+// advance reads the next token from the inner XML and moves the offset of the
+// bytes that are known to be part of the inner XML forward.
+func (r *InnerXMLReader) advance() {
+	off := r.d.InputOffset()
+	tok, err := r.d.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		r.err = err
+		return
+	}
+	switch tok.(type) {
+	case xml.StartElement:
+		r.depth++
+	case xml.EndElement:
+		// The decoder has already verified that the end element matches its start
+		// element.
+		if r.depth == 0 {
+			r.end = off
+			r.safe = off
+			r.err = io.EOF
+			return
+		}
+		r.depth--
+	}
+	r.safe = r.d.InputOffset()
+}
+
+// Read reads the inner XML into p.
+func (r *InnerXMLReader) Read(p []byte) (int, error) {
+	if _, err := r.Start(); err != nil {
+		return 0, err
+	}
+	for {
+		if r.safe > r.rec.off {
+			n := copy(p, r.rec.bytes(r.rec.off, r.safe))
+			r.rec.drop(r.rec.off + int64(n))
+			return n, nil
+		}
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.advance()
+	}
+}
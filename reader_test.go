@@ -131,3 +131,96 @@ func TestInnerReader(t *testing.T) {
 		})
 	}
 }
+
+var innerXMLReaderTests = [...]struct {
+	in    string
+	start xml.Name
+	read  string
+	begin int64
+	end   int64
+	err   bool
+}{
+	0: {begin: -1, end: -1},
+	1: {
+		in:    `<test></test>`,
+		start: xml.Name{Local: "test"},
+		begin: 6,
+		end:   6,
+	},
+	2: {
+		in:    `<test><inner/>Inner</test >`,
+		start: xml.Name{Local: "test"},
+		read:  `<inner/>Inner`,
+		begin: 6,
+		end:   19,
+	},
+	3: {
+		in:    `<stream:stream xmlns:stream="urn:stream"><stream:features></stream:features></stream:stream	>`,
+		start: xml.Name{Space: "urn:stream", Local: "stream"},
+		read:  `<stream:features></stream:features>`,
+		begin: 41,
+		end:   76,
+	},
+	4: {
+		in:    ` <test/>`,
+		start: xml.Name{Local: "test"},
+		begin: 8,
+		end:   8,
+	},
+	5: {
+		in:    `<a><b></a>`,
+		start: xml.Name{Local: "a"},
+		begin: 3,
+		end:   -1,
+		err:   true,
+	},
+	6: {
+		in:    `<a>unterminated`,
+		start: xml.Name{Local: "a"},
+		begin: 3,
+		end:   -1,
+		err:   true,
+	},
+	7: {
+		in:    `<!-- Test --><a/>`,
+		begin: -1,
+		end:   -1,
+		err:   true,
+	},
+	8: {
+		in:    `<a><a></a></a><b/>`,
+		start: xml.Name{Local: "a"},
+		read:  `<a></a>`,
+		begin: 3,
+		end:   10,
+	},
+}
+
+func TestInnerXMLReader(t *testing.T) {
+	for i, tc := range innerXMLReaderTests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			ir := NewInnerXMLReader(strings.NewReader(tc.in))
+			b, err := io.ReadAll(ir)
+			switch {
+			case err != nil && !tc.err:
+				t.Fatalf("Unexpected error: %v", err)
+			case err == nil && tc.err:
+				t.Fatalf("Expected error, got none")
+			}
+			if !tc.err && string(b) != tc.read {
+				t.Errorf("Unexpected value read: want=`%s`, got=`%s`", tc.read, b)
+			}
+			start, _ := ir.Start()
+			if start.Name != tc.start {
+				t.Errorf("Unexpected start element: want=%v, got=%v", tc.start, start.Name)
+			}
+			begin, end := ir.Offset()
+			if begin != tc.begin || end != tc.end {
+				t.Errorf("Unexpected offsets: want=(%d, %d), got=(%d, %d)", tc.begin, tc.end, begin, end)
+			}
+			if end >= 0 && tc.in[begin:end] != tc.read {
+				t.Errorf("Offsets do not match inner XML: want=`%s`, got=`%s`", tc.read, tc.in[begin:end])
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+)
+
+// RenameOption configures the Rename and RenameNamespace transformers.
+type RenameOption func(*renamer)
+
+// RenameWithin limits renaming to elements that match name and their
+// descendants.
+// If either component of the name is empty it is considered a wildcard.
+func RenameWithin(name xml.Name) RenameOption {
+	return func(r *renamer) {
+		r.within = &name
+	}
+}
+
+// Rename returns a Transformer that renames elements using the given map from
+// old to new names.
+// Unlike Map, it remembers the name each start element was given and uses it
+// for the matching end element, so elements can be renamed based on their
+// context and the output stays well formed.
+// Attributes are not renamed, but if an element that is moved to a new
+// namespace declares its old namespace as the default namespace, the
+// declaration is changed to the new namespace.
+func Rename(names map[xml.Name]xml.Name, opts ...RenameOption) Transformer {
+	return newRenamer(func(start xml.StartElement) xml.StartElement {
+		name, ok := names[start.Name]
+		if !ok {
+			return start
+		}
+		if name.Space != start.Name.Space {
+			for i, attr := range start.Attr {
+				if attr.Name == (xml.Name{Local: "xmlns"}) && attr.Value == start.Name.Space {
+					start = start.Copy()
+					start.Attr[i].Value = name.Space
+					break
+				}
+			}
+		}
+		start.Name = name
+		return start
+	}, opts)
+}
+
+// RenameNamespace returns a Transformer that moves elements and attributes from
+// the old namespace to the new namespace, for example when migrating a payload
+// to a new version of a protocol.
+// Namespace declarations for the old namespace are changed to declare the new
+// namespace.
+// Names are expected to contain namespace URIs, as in tokens returned by the
+// Token method of xml.Decoder.
+func RenameNamespace(old, new string, opts ...RenameOption) Transformer {
+	return newRenamer(func(start xml.StartElement) xml.StartElement {
+		if start.Name.Space == old {
+			start.Name.Space = new
+		}
+		var attrs []xml.Attr
+		for i, attr := range start.Attr {
+			isDecl := attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns")
+			switch {
+			case isDecl && attr.Value == old:
+				attr.Value = new
+			case !isDecl && attr.Name.Space == old:
+				attr.Name.Space = new
+			default:
+				continue
+			}
+			if attrs == nil {
+				attrs = make([]xml.Attr, len(start.Attr))
+				copy(attrs, start.Attr)
+			}
+			attrs[i] = attr
+		}
+		if attrs != nil {
+			start.Attr = attrs
+		}
+		return start
+	}, opts)
+}
+
+func newRenamer(f func(xml.StartElement) xml.StartElement, opts []RenameOption) Transformer {
+	return func(r xml.TokenReader) xml.TokenReader {
+		rn := &renamer{r: r, f: f}
+		for _, opt := range opts {
+			opt(rn)
+		}
+		return rn
+	}
+}
+
+type renamer struct {
+	r      xml.TokenReader
+	f      func(xml.StartElement) xml.StartElement
+	within *xml.Name
+	// depth is the nesting depth inside the selected subtree, or 0 if outside
+	// of it.
+	depth int
+	stack []xml.Name
+}
+
+func (rn *renamer) Token() (xml.Token, error) {
+	tok, err := rn.r.Token()
+	switch t := tok.(type) {
+	case xml.StartElement:
+		switch {
+		case rn.depth > 0:
+			rn.depth++
+		case rn.within != nil && nameMatches(*rn.within, t.Name):
+			rn.depth = 1
+		}
+		if rn.within == nil || rn.depth > 0 {
+			t = rn.f(t)
+		}
+		rn.stack = append(rn.stack, t.Name)
+		return t, err
+	case xml.EndElement:
+		if rn.depth > 0 {
+			rn.depth--
+		}
+		if len(rn.stack) > 0 {
+			t.Name = rn.stack[len(rn.stack)-1]
+			rn.stack = rn.stack[:len(rn.stack)-1]
+		}
+		return t, err
+	}
+	return tok, err
+}
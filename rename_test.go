@@ -0,0 +1,89 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+var renameTests = [...]struct {
+	t   xmlstream.Transformer
+	in  string
+	out string
+}{
+	0: {
+		t:   xmlstream.Rename(nil),
+		in:  `<a><b/></a>`,
+		out: `<a><b></b></a>`,
+	},
+	1: {
+		t: xmlstream.Rename(map[xml.Name]xml.Name{
+			{Space: "urn:old", Local: "a"}: {Space: "urn:new", Local: "x"},
+			{Space: "urn:old", Local: "b"}: {Space: "urn:old", Local: "y"},
+		}),
+		in:  `<a xmlns="urn:old"><b>text</b><c/></a>`,
+		out: `<x xmlns="urn:new"><y xmlns="urn:old">text</y><c xmlns="urn:old"></c></x>`,
+	},
+	2: {
+		t: xmlstream.Rename(map[xml.Name]xml.Name{
+			{Local: "body"}: {Local: "text"},
+		}, xmlstream.RenameWithin(xml.Name{Local: "html"})),
+		in:  `<message><body>a</body><html><body>b</body></html><body>c</body></message>`,
+		out: `<message><body>a</body><html><text>b</text></html><body>c</body></message>`,
+	},
+	3: {
+		t: xmlstream.Rename(map[xml.Name]xml.Name{
+			{Local: "html"}: {Local: "xhtml"},
+		}, xmlstream.RenameWithin(xml.Name{Local: "html"})),
+		in:  `<html><html/></html><html/>`,
+		out: `<xhtml><xhtml></xhtml></xhtml><xhtml></xhtml>`,
+	},
+	4: {
+		t:   xmlstream.RenameNamespace("urn:xmpp:foo:0", "urn:xmpp:foo:1"),
+		in:  `<iq xmlns="jabber:client"><foo xmlns="urn:xmpp:foo:0"><bar/></foo><baz xmlns="urn:other"/></iq>`,
+		out: `<iq xmlns="jabber:client"><foo xmlns="urn:xmpp:foo:1"><bar></bar></foo><baz xmlns="urn:other"></baz></iq>`,
+	},
+	5: {
+		t:   xmlstream.RenameNamespace("urn:old", "urn:new"),
+		in:  `<a xmlns:o="urn:old" o:attr="1" attr="2"><o:b/></a>`,
+		out: `<a xmlns:o="urn:new" o:attr="1" attr="2"><o:b></o:b></a>`,
+	},
+	6: {
+		t:   xmlstream.RenameNamespace("urn:old", "urn:new", xmlstream.RenameWithin(xml.Name{Local: "payload"})),
+		in:  `<x xmlns="urn:old"><payload xmlns="urn:a"><y xmlns="urn:old"/></payload><z/></x>`,
+		out: `<x xmlns="urn:old"><payload xmlns="urn:a"><y xmlns="urn:new"></y></payload><z></z></x>`,
+	},
+	7: {
+		t: xmlstream.Rename(map[xml.Name]xml.Name{
+			{Space: "urn:old", Local: "a"}: {Space: "urn:new", Local: "a"},
+		}),
+		in:  `<p:a xmlns:p="urn:old" xmlns="urn:d"><b/></p:a>`,
+		out: `<ns1:a xmlns:ns1="urn:new" xmlns:p="urn:old" xmlns="urn:d"><b></b></ns1:a>`,
+	},
+}
+
+func TestRename(t *testing.T) {
+	for i, tc := range renameTests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r := tc.t(xml.NewDecoder(strings.NewReader(tc.in)))
+			var buf strings.Builder
+			w := xmlstream.XMLWriter(&buf)
+			if _, err := xmlstream.Copy(w, r); err != nil {
+				t.Fatalf("error copying tokens: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("error closing writer: %v", err)
+			}
+			if out := buf.String(); out != tc.out {
+				t.Errorf("wrong output:\nwant=%s,\n got=%s", tc.out, out)
+			}
+		})
+	}
+}
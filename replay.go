@@ -0,0 +1,195 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrReplayFull is returned by a ReplayWriter when starting a new element
+	// would exceed the number of unacknowledged elements it can hold.
+	ErrReplayFull = errors.New("xmlstream: too many unacknowledged elements")
+
+	errBadAck = errors.New("xmlstream: invalid acknowledgement")
+)
+
+// ReplayWriter is a TokenWriter that counts the elements written at a given
+// depth and keeps copies of them until they are acknowledged so that they can
+// be resent, for example to implement stream management in XMPP.
+// It is safe to call Ack concurrently with the other methods.
+type ReplayWriter struct {
+	mu    sync.Mutex
+	w     TokenWriter
+	depth int
+	limit int
+	cur   int
+	elem  []xml.Token
+	queue [][]xml.Token
+	sent  uint32
+	acked uint32
+}
+
+// NewReplayWriter returns a ReplayWriter that writes tokens to w and keeps
+// copies of up to limit unacknowledged elements at depth, where a depth of 1
+// is the top level of the tokens written to w.
+// If limit <= 0, the number of unacknowledged elements is unlimited.
+// Tokens that are not part of an element at that depth are written but not
+// counted or kept.
+func NewReplayWriter(w TokenWriter, depth, limit int) *ReplayWriter {
+	return &ReplayWriter{w: w, depth: depth, limit: limit}
+}
+
+// EncodeToken writes a token to the underlying writer and records it if it is
+// part of an element that will be counted.
+// It returns ErrReplayFull without writing the token if it starts a new
+// element and there are already limit unacknowledged elements.
+//
+// Tokens are recorded even if the underlying writer returns an error, so an
+// element whose end element could not be written is still counted by Sent and
+// will be resent by Replay after the writer is reset.
+func (r *ReplayWriter) EncodeToken(t xml.Token) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch t.(type) {
+	case xml.StartElement:
+		if r.cur == r.depth-1 {
+			if r.limit > 0 && len(r.queue) >= r.limit {
+				return ErrReplayFull
+			}
+			r.elem = []xml.Token{}
+		}
+		r.cur++
+	case xml.EndElement:
+		r.cur--
+	}
+	if r.elem != nil {
+		r.elem = append(r.elem, xml.CopyToken(t))
+		if _, ok := t.(xml.EndElement); ok && r.cur == r.depth-1 {
+			r.queue = append(r.queue, r.elem)
+			r.elem = nil
+			r.sent++
+		}
+	}
+	return r.w.EncodeToken(t)
+}
+
+// Flush flushes the underlying writer if it implements Flusher.
+func (r *ReplayWriter) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.w.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Sent returns the number of complete elements that have been written,
+// wrapping around at 2^32.
+func (r *ReplayWriter) Sent() uint32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sent
+}
+
+// Unacked returns the number of elements that have not been acknowledged.
+func (r *ReplayWriter) Unacked() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.queue)
+}
+
+// Ack acknowledges all elements up to and including the h'th element sent,
+// wrapping around at 2^32, and removes them from the replay queue.
+// It returns an error if h is more than the number of elements sent or less
+// than a previous acknowledgement.
+func (r *ReplayWriter) Ack(h uint32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := h - r.acked
+	if n > uint32(len(r.queue)) {
+		return errBadAck
+	}
+	for i := uint32(0); i < n; i++ {
+		r.queue[i] = nil
+	}
+	r.queue = r.queue[n:]
+	r.acked = h
+	return nil
+}
+
+// Reset replaces the underlying writer, for example after reconnecting.
+// Any element that was only partially written to the old writer is discarded,
+// and the depth is reset as if no tokens had been written.
+// Unacknowledged elements are kept so that they can be resent with Replay.
+func (r *ReplayWriter) Reset(w TokenWriter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w = w
+	r.cur = 0
+	r.elem = nil
+}
+
+// Replay writes all unacknowledged elements to w, which should be the writer
+// that r writes to and not r itself, and then flushes w if it is a Flusher.
+// The elements are not counted again and remain in the queue until they are
+// acknowledged.
+func (r *ReplayWriter) Replay(w TokenWriter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, elem := range r.queue {
+		for _, t := range elem {
+			if err := w.EncodeToken(t); err != nil {
+				return err
+			}
+		}
+	}
+	if f, ok := w.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// CountingReader is an xml.TokenReader that counts the elements read at a
+// given depth.
+type CountingReader struct {
+	r     xml.TokenReader
+	depth int
+	cur   int
+	n     atomic.Uint32
+}
+
+// NewCountingReader returns a CountingReader that reads tokens from r and
+// counts the elements at depth, where a depth of 1 is the top level of the
+// tokens read from r.
+func NewCountingReader(r xml.TokenReader, depth int) *CountingReader {
+	return &CountingReader{r: r, depth: depth}
+}
+
+// Token returns the next token from the underlying reader.
+func (c *CountingReader) Token() (xml.Token, error) {
+	t, err := c.r.Token()
+	switch t.(type) {
+	case xml.StartElement:
+		c.cur++
+	case xml.EndElement:
+		if c.cur == c.depth {
+			c.n.Add(1)
+		}
+		c.cur--
+	}
+	return t, err
+}
+
+// Count returns the number of complete elements that have been read, wrapping
+// around at 2^32.
+// It is safe to call Count concurrently with Token.
+func (c *CountingReader) Count() uint32 {
+	return c.n.Load()
+}
@@ -0,0 +1,153 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+func writeStanza(t *testing.T, w xmlstream.TokenWriter, id int) error {
+	t.Helper()
+	start := xml.StartElement{
+		Name: xml.Name{Local: "message"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "id"}, Value: strconv.Itoa(id)}},
+	}
+	_, err := xmlstream.Copy(w, xmlstream.Wrap(xmlstream.Token(xml.CharData("hi")), start))
+	return err
+}
+
+func TestReplayWriter(t *testing.T) {
+	var buf strings.Builder
+	e := xml.NewEncoder(&buf)
+	rw := xmlstream.NewReplayWriter(e, 2, 3)
+	stream := xmlstream.NewStreamWriter(rw, xml.StartElement{Name: xml.Name{Local: "stream"}})
+	if err := stream.Open(); err != nil {
+		t.Fatalf("error opening stream: %v", err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		if err := writeStanza(t, rw, i); err != nil {
+			t.Fatalf("error writing stanza %d: %v", i, err)
+		}
+	}
+	// Whitespace keepalives are not counted.
+	if err := rw.EncodeToken(xml.CharData(" ")); err != nil {
+		t.Fatalf("error writing whitespace: %v", err)
+	}
+	if err := writeStanza(t, rw, 4); err != xmlstream.ErrReplayFull {
+		t.Errorf("wrong error for full queue: want=%v, got=%v", xmlstream.ErrReplayFull, err)
+	}
+	if sent, unacked := rw.Sent(), rw.Unacked(); sent != 3 || unacked != 3 {
+		t.Errorf("wrong counts: sent=%d, unacked=%d", sent, unacked)
+	}
+
+	if err := rw.Ack(1); err != nil {
+		t.Fatalf("error acking: %v", err)
+	}
+	if err := rw.Ack(5); err == nil {
+		t.Errorf("expected error acking more elements than were sent")
+	}
+	if err := rw.Ack(0); err == nil {
+		t.Errorf("expected error acking fewer elements than before")
+	}
+	if n := rw.Unacked(); n != 2 {
+		t.Errorf("wrong unacked count after ack: want=2, got=%d", n)
+	}
+	if err := writeStanza(t, rw, 4); err != nil {
+		t.Fatalf("error writing stanza after ack: %v", err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+
+	// Resume on a new connection: open a new stream and resend everything the
+	// other side has not acknowledged.
+	var resumed strings.Builder
+	e = xml.NewEncoder(&resumed)
+	rw.Reset(e)
+	stream.Reset(rw)
+	if err := stream.Open(); err != nil {
+		t.Fatalf("error reopening stream: %v", err)
+	}
+	if err := rw.Ack(2); err != nil {
+		t.Fatalf("error acking on resume: %v", err)
+	}
+	if err := rw.Replay(e); err != nil {
+		t.Fatalf("error replaying: %v", err)
+	}
+	if err := writeStanza(t, rw, 5); err != nil {
+		t.Fatalf("error writing after replay: %v", err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("error closing stream: %v", err)
+	}
+
+	const want = `<stream><message id="3">hi</message><message id="4">hi</message><message id="5">hi</message></stream>`
+	if out := resumed.String(); out != want {
+		t.Errorf("wrong resumed stream:\nwant=%s,\n got=%s", want, out)
+	}
+	if sent := rw.Sent(); sent != 5 {
+		t.Errorf("wrong sent count: want=5, got=%d", sent)
+	}
+}
+
+func TestReplayWriterUnlimited(t *testing.T) {
+	rw := xmlstream.NewReplayWriter(xmlstream.Discard(), 1, 0)
+	for i := 1; i <= 100; i++ {
+		if err := writeStanza(t, rw, i); err != nil {
+			t.Fatalf("error writing stanza %d: %v", i, err)
+		}
+	}
+	if n := rw.Unacked(); n != 100 {
+		t.Errorf("wrong unacked count: want=100, got=%d", n)
+	}
+}
+
+// failWriter is a TokenWriter that fails when it is passed an end element.
+type failWriter struct{}
+
+func (failWriter) EncodeToken(t xml.Token) error {
+	if _, ok := t.(xml.EndElement); ok {
+		return errors.New("write failed")
+	}
+	return nil
+}
+
+func TestReplayWriterFailedWrite(t *testing.T) {
+	rw := xmlstream.NewReplayWriter(failWriter{}, 1, 0)
+	if err := writeStanza(t, rw, 1); err == nil {
+		t.Fatalf("expected error writing stanza")
+	}
+	if sent, unacked := rw.Sent(), rw.Unacked(); sent != 1 || unacked != 1 {
+		t.Errorf("wrong counts after failed write: sent=%d, unacked=%d", sent, unacked)
+	}
+
+	var buf strings.Builder
+	e := xml.NewEncoder(&buf)
+	rw.Reset(e)
+	if err := rw.Replay(e); err != nil {
+		t.Fatalf("error replaying: %v", err)
+	}
+	if want := `<message id="1">hi</message>`; buf.String() != want {
+		t.Errorf("wrong replayed output: want=%s, got=%s", want, buf.String())
+	}
+}
+
+func TestCountingReader(t *testing.T) {
+	const in = `<stream><message><body/></message> <iq/><presence/></stream>`
+	r := xmlstream.NewCountingReader(xml.NewDecoder(strings.NewReader(in)), 2)
+	if _, err := xmlstream.ReadAll(r); err != nil {
+		t.Fatalf("error reading: %v", err)
+	}
+	if n := r.Count(); n != 3 {
+		t.Errorf("wrong count: want=3, got=%d", n)
+	}
+}
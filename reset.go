@@ -0,0 +1,52 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+	"io"
+	"sync/atomic"
+)
+
+// ResettableReader is an xml.TokenReader that reads tokens from an underlying
+// reader that can be replaced at any time, such as after negotiating TLS or
+// compression on a connection.
+// Readers and transformers that wrap a ResettableReader keep working unchanged
+// after it is reset.
+//
+// It is safe to call Reset concurrently with Token, but a call to Token that
+// is blocked on the old reader will still return its result.
+type ResettableReader struct {
+	r atomic.Pointer[tokenReaderBox]
+}
+
+// tokenReaderBox lets readers of different concrete types be stored in an
+// atomic.Pointer.
+type tokenReaderBox struct {
+	xml.TokenReader
+}
+
+// NewResettableReader returns a ResettableReader that reads tokens from r.
+func NewResettableReader(r xml.TokenReader) *ResettableReader {
+	rr := &ResettableReader{}
+	rr.Reset(r)
+	return rr
+}
+
+// Token returns the next token from the current underlying reader.
+func (r *ResettableReader) Token() (xml.Token, error) {
+	return r.r.Load().Token()
+}
+
+// Reset replaces the underlying reader with tr.
+func (r *ResettableReader) Reset(tr xml.TokenReader) {
+	r.r.Store(&tokenReaderBox{TokenReader: tr})
+}
+
+// ResetReader replaces the underlying reader with a new xml.Decoder that reads
+// from ir.
+func (r *ResettableReader) ResetReader(ir io.Reader) {
+	r.Reset(xml.NewDecoder(ir))
+}
@@ -0,0 +1,62 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+func TestResettableReader(t *testing.T) {
+	rr := xmlstream.NewResettableReader(xml.NewDecoder(strings.NewReader(`<stream><starttls/><proceed/>`)))
+	var names []string
+	r := xmlstream.Inspect(func(tok xml.Token) {
+		if start, ok := tok.(xml.StartElement); ok {
+			names = append(names, start.Name.Local)
+		}
+	})(rr)
+
+	for i := 0; i < 5; i++ {
+		if _, err := r.Token(); err != nil {
+			t.Fatalf("error reading token %d: %v", i, err)
+		}
+	}
+
+	// Swap the underlying reader as if TLS had been negotiated, and continue
+	// using the same chain.
+	rr.ResetReader(strings.NewReader(`<features/><message/>`))
+	if _, err := xmlstream.ReadAll(r); err != nil {
+		t.Fatalf("error reading after reset: %v", err)
+	}
+
+	const want = "stream starttls proceed features message"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("wrong elements: want=%q, got=%q", want, got)
+	}
+}
+
+func TestResettableReaderConcurrent(t *testing.T) {
+	rr := xmlstream.NewResettableReader(xmlstream.Token(xml.CharData("a")))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			rr.Reset(xmlstream.Token(xml.CharData("b")))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_, err := rr.Token()
+		if err != nil && err != io.EOF {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+)
+
+// ScopeReader is an xml.TokenReader that keeps track of the inherited xml:lang
+// and xml:base attributes of the elements it reads.
+//
+// After a start element is read, Lang and Base return the values in effect for
+// that element, and after its end element is read they return the values in
+// effect for its parent.
+// This means that transformers wrapping a ScopeReader, and users of an Iter
+// created from one, can look up the scope of the element they just read.
+type ScopeReader struct {
+	r     xml.TokenReader
+	base  *url.URL
+	stack []langBase
+}
+
+type langBase struct {
+	lang string
+	base *url.URL
+}
+
+// NewScopeReader returns a ScopeReader that reads tokens from r.
+// The base URI of the document is used to resolve relative xml:base
+// attributes and may be nil.
+func NewScopeReader(r xml.TokenReader, base *url.URL) *ScopeReader {
+	return &ScopeReader{r: r, base: base}
+}
+
+func isXMLAttr(name xml.Name, local string) bool {
+	return name.Local == local && (name.Space == xmlNS || name.Space == "xml")
+}
+
+// Token returns the next token from the underlying reader.
+// It returns an error if an xml:base attribute is not a valid URI reference, in
+// which case the element inherits the base URI of its parent so that reading
+// can continue.
+func (s *ScopeReader) Token() (xml.Token, error) {
+	tok, err := s.r.Token()
+	switch t := tok.(type) {
+	case xml.StartElement:
+		scope := langBase{lang: s.Lang(), base: s.Base()}
+		for _, attr := range t.Attr {
+			switch {
+			case isXMLAttr(attr.Name, "lang"):
+				scope.lang = attr.Value
+			case isXMLAttr(attr.Name, "base"):
+				u, perr := s.Resolve(attr.Value)
+				if perr != nil {
+					if err == nil {
+						err = fmt.Errorf("xmlstream: invalid xml:base %q: %w", attr.Value, perr)
+					}
+					continue
+				}
+				scope.base = u
+			}
+		}
+		s.stack = append(s.stack, scope)
+	case xml.EndElement:
+		if len(s.stack) > 0 {
+			s.stack = s.stack[:len(s.stack)-1]
+		}
+	}
+	return tok, err
+}
+
+// Lang returns the xml:lang in effect for the most recently started element
+// that has not ended, or the empty string if it is unknown.
+func (s *ScopeReader) Lang() string {
+	if len(s.stack) == 0 {
+		return ""
+	}
+	return s.stack[len(s.stack)-1].lang
+}
+
+// Base returns the base URI in effect for the most recently started element
+// that has not ended, or the document base if there is no such element.
+// The returned URL should not be modified.
+func (s *ScopeReader) Base() *url.URL {
+	if len(s.stack) == 0 {
+		return s.base
+	}
+	return s.stack[len(s.stack)-1].base
+}
+
+// Resolve resolves a URI reference against the current base URI.
+// If there is no base URI, the reference is returned as is.
+func (s *ScopeReader) Resolve(ref string) (*url.URL, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	if base := s.Base(); base != nil {
+		u = base.ResolveReference(u)
+	}
+	return u, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+func TestScopeReader(t *testing.T) {
+	const in = `<doc xml:lang="en" xml:base="http://example.com/a/"><p xml:base="b/"><img src="c.png"/><q xml:lang="fr" xml:base="/root/">x</q></p><p xml:lang=""/></doc>`
+	doc, _ := url.Parse("http://example.net/doc.xml")
+	s := xmlstream.NewScopeReader(xml.NewDecoder(strings.NewReader(in)), doc)
+
+	if s.Base() != doc || s.Lang() != "" {
+		t.Fatalf("wrong initial scope: lang=%q, base=%v", s.Lang(), s.Base())
+	}
+
+	var got []string
+	for {
+		tok, err := s.Token()
+		if err != nil {
+			break
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			got = append(got, start.Name.Local+" "+s.Lang()+" "+s.Base().String())
+			if start.Name.Local == "img" {
+				u, err := s.Resolve(start.Attr[0].Value)
+				if err != nil {
+					t.Fatalf("error resolving src: %v", err)
+				}
+				if u.String() != "http://example.com/a/b/c.png" {
+					t.Errorf("wrong resolved src: %s", u)
+				}
+			}
+		}
+		if end, ok := tok.(xml.EndElement); ok && end.Name.Local == "q" {
+			got = append(got, "/q "+s.Lang()+" "+s.Base().String())
+		}
+	}
+
+	want := []string{
+		"doc en http://example.com/a/",
+		"p en http://example.com/a/b/",
+		"img en http://example.com/a/b/",
+		"q fr http://example.com/root/",
+		"/q en http://example.com/a/b/",
+		"p  http://example.com/a/",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("wrong scopes:\nwant=%q,\n got=%q", want, got)
+	}
+}
+
+func TestScopeReaderInvalidBase(t *testing.T) {
+	base, err := url.Parse("http://example.net/doc/")
+	if err != nil {
+		t.Fatalf("error parsing base: %v", err)
+	}
+	const in = `<a xml:base="a/"><b xml:base="%zz" xml:lang="en"><c/></b><d/></a>`
+	s := xmlstream.NewScopeReader(xml.NewDecoder(strings.NewReader(in)), base)
+	var got []string
+	var errs int
+	for {
+		tok, err := s.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs++
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			got = append(got, start.Name.Local+"="+s.Base().String()+","+s.Lang())
+		}
+	}
+	if errs != 1 {
+		t.Errorf("expected one error for invalid xml:base, got %d", errs)
+	}
+	want := "a=http://example.net/doc/a/, b=http://example.net/doc/a/,en c=http://example.net/doc/a/,en d=http://example.net/doc/a/,"
+	if s := strings.Join(got, " "); s != want {
+		t.Errorf("wrong scopes after error:\nwant=%s,\n got=%s", want, s)
+	}
+}
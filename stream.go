@@ -0,0 +1,218 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+var (
+	errStreamStart  = errors.New("xmlstream: expected stream start element")
+	errStreamText   = errors.New("xmlstream: unexpected text in stream")
+	errStreamOpen   = errors.New("xmlstream: stream already open")
+	errStreamClosed = errors.New("xmlstream: stream not open")
+)
+
+// StreamReader reads a long-lived stream made up of a single root element
+// whose children are read one at a time, such as an XMPP stream.
+//
+// The XML declaration, comments, and processing instructions before the root
+// element are skipped, as is whitespace between children.
+type StreamReader struct {
+	br      *bufio.Reader
+	d       *xml.Decoder
+	start   xml.StartElement
+	started bool
+	closed  bool
+	next    xml.StartElement
+	cur     xml.TokenReader
+	err     error
+	discard TokenWriter
+}
+
+// NewStreamReader returns a StreamReader that reads a stream from r.
+func NewStreamReader(r io.Reader) *StreamReader {
+	s := &StreamReader{discard: Discard()}
+	s.Reset(r)
+	return s
+}
+
+// Reset restarts the stream so that the next call to Start or Next reads a
+// new root element.
+// If r is nil the stream is restarted on the same reader without losing any
+// data that has already been buffered, otherwise r replaces the old reader
+// (for example, after negotiating TLS).
+func (s *StreamReader) Reset(r io.Reader) {
+	if r != nil {
+		s.br = bufio.NewReader(r)
+	}
+	s.d = xml.NewDecoder(s.br)
+	s.start = xml.StartElement{}
+	s.next = xml.StartElement{}
+	s.started = false
+	s.closed = false
+	s.cur = nil
+	s.err = nil
+}
+
+// Start returns the root element of the stream, reading it if it has not yet
+// been read.
+func (s *StreamReader) Start() (xml.StartElement, error) {
+	if s.started || s.err != nil {
+		return s.start, s.err
+	}
+	for {
+		tok, err := s.d.Token()
+		if err != nil {
+			s.err = err
+			return s.start, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			s.start = t.Copy()
+			s.started = true
+			return s.start, nil
+		case xml.CharData:
+			if !isWhitespace(t) {
+				s.err = errStreamStart
+				return s.start, s.err
+			}
+		case xml.EndElement:
+			s.err = errStreamStart
+			return s.start, s.err
+		}
+	}
+}
+
+// Next advances to the next child of the root element, reading the root
+// element first if necessary.
+// Any part of the previous child that was not read is skipped.
+// It returns false when the root element is closed or an error occurs.
+func (s *StreamReader) Next() bool {
+	if _, err := s.Start(); err != nil || s.closed {
+		return false
+	}
+
+	if s.cur != nil {
+		_, s.err = Copy(s.discard, s.cur)
+		s.cur = nil
+		if s.err != nil {
+			return false
+		}
+	}
+
+	for {
+		tok, err := s.d.Token()
+		if err != nil {
+			s.err = unexpectedEOF(err)
+			return false
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			s.next = t.Copy()
+			s.cur = InnerElement(s.d)
+			return true
+		case xml.EndElement:
+			s.closed = true
+			return false
+		case xml.CharData:
+			if !isWhitespace(t) {
+				s.err = errStreamText
+				return false
+			}
+		}
+	}
+}
+
+// Current returns the start element of the current child and a reader that is
+// limited to the rest of the child, including its end element.
+func (s *StreamReader) Current() (xml.StartElement, xml.TokenReader) {
+	return s.next, s.cur
+}
+
+// Closed reports whether the end of the root element has been read.
+func (s *StreamReader) Closed() bool {
+	return s.closed
+}
+
+// Err returns the last error encountered by the reader (if any).
+// Closing the root element is not an error.
+func (s *StreamReader) Err() error {
+	return s.err
+}
+
+// StreamWriter writes the start and end of a long-lived stream made up of a
+// single root element, such as an XMPP stream.
+// Tokens written between Open and Close are written to the underlying
+// TokenWriter and the start and end of the stream are flushed immediately if it
+// is a Flusher.
+type StreamWriter struct {
+	w     TokenWriter
+	start xml.StartElement
+	open  bool
+}
+
+// NewStreamWriter returns a StreamWriter that writes a stream with the given
+// root element to w.
+func NewStreamWriter(w TokenWriter, start xml.StartElement) *StreamWriter {
+	return &StreamWriter{w: w, start: start.Copy()}
+}
+
+// Open writes the start element of the stream.
+func (s *StreamWriter) Open() error {
+	if s.open {
+		return errStreamOpen
+	}
+	if err := s.w.EncodeToken(s.start); err != nil {
+		return err
+	}
+	s.open = true
+	return s.Flush()
+}
+
+// EncodeToken writes a token in the stream.
+// It returns an error if the stream is not open.
+func (s *StreamWriter) EncodeToken(t xml.Token) error {
+	if !s.open {
+		return errStreamClosed
+	}
+	return s.w.EncodeToken(t)
+}
+
+// Flush flushes the underlying TokenWriter if it is a Flusher.
+func (s *StreamWriter) Flush() error {
+	if f, ok := s.w.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close writes the end element of the stream if it is open.
+// It does not close the underlying TokenWriter.
+func (s *StreamWriter) Close() error {
+	if !s.open {
+		return nil
+	}
+	s.open = false
+	if err := s.w.EncodeToken(s.start.End()); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+// Reset forgets that the stream was opened so that a new stream can be started
+// with Open.
+// If w is not nil it replaces the underlying TokenWriter, which is necessary
+// for writers such as xml.Encoder that keep track of open elements.
+// The old TokenWriter is not flushed.
+func (s *StreamWriter) Reset(w TokenWriter) {
+	if w != nil {
+		s.w = w
+	}
+	s.open = false
+}
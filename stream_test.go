@@ -0,0 +1,182 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+const (
+	streamHeader = `<?xml version='1.0'?><stream:stream xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams' id='%s'>`
+	streamNS     = "http://etherx.jabber.org/streams"
+)
+
+func streamStart(id string) string {
+	return strings.Replace(streamHeader, "%s", id, 1)
+}
+
+func encodeChild(t *testing.T, s *xmlstream.StreamReader) string {
+	t.Helper()
+	start, r := s.Current()
+	var buf strings.Builder
+	e := xml.NewEncoder(&buf)
+	_, err := xmlstream.Copy(e, xmlstream.MultiReader(xmlstream.Token(start), r))
+	if err != nil {
+		t.Fatalf("error encoding child: %v", err)
+	}
+	if err = e.Flush(); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+	return buf.String()
+}
+
+func TestStreamReader(t *testing.T) {
+	in := streamStart("1") + `<stream:features><starttls/></stream:features> <proceed/>` +
+		streamStart("2") + "\n<message><body>hi</body></message><!-- ping -->\n<iq><query><item/></query></iq></stream:stream>"
+	s := xmlstream.NewStreamReader(strings.NewReader(in))
+
+	start, err := s.Start()
+	if err != nil {
+		t.Fatalf("error reading start: %v", err)
+	}
+	if start.Name != (xml.Name{Space: streamNS, Local: "stream"}) || start.Attr[2].Value != "1" {
+		t.Errorf("wrong stream start: %+v", start)
+	}
+
+	if !s.Next() {
+		t.Fatalf("expected features: %v", s.Err())
+	}
+	const features = `<features xmlns="http://etherx.jabber.org/streams"><starttls xmlns="jabber:client"></starttls></features>`
+	if out := encodeChild(t, s); out != features {
+		t.Errorf("wrong features:\nwant=%s,\n got=%s", features, out)
+	}
+	if !s.Next() {
+		t.Fatalf("expected proceed: %v", s.Err())
+	}
+	if start, _ := s.Current(); start.Name.Local != "proceed" {
+		t.Errorf("wrong child: want=proceed, got=%s", start.Name.Local)
+	}
+
+	// Restart the stream on the same reader.
+	s.Reset(nil)
+	start, err = s.Start()
+	if err != nil {
+		t.Fatalf("error reading restarted start: %v", err)
+	}
+	if start.Attr[2].Value != "2" {
+		t.Errorf("wrong restarted stream: %+v", start)
+	}
+
+	if !s.Next() {
+		t.Fatalf("expected message: %v", s.Err())
+	}
+	const message = `<message xmlns="jabber:client"><body xmlns="jabber:client">hi</body></message>`
+	if out := encodeChild(t, s); out != message {
+		t.Errorf("wrong message:\nwant=%s,\n got=%s", message, out)
+	}
+	// Leave the iq partially read.
+	if !s.Next() {
+		t.Fatalf("expected iq: %v", s.Err())
+	}
+	_, r := s.Current()
+	if _, err := r.Token(); err != nil {
+		t.Fatalf("error reading iq: %v", err)
+	}
+	if s.Next() {
+		t.Errorf("expected stream to be closed")
+	}
+	if err := s.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !s.Closed() {
+		t.Errorf("expected Closed to report true")
+	}
+}
+
+func TestStreamReaderReset(t *testing.T) {
+	s := xmlstream.NewStreamReader(strings.NewReader(streamStart("1") + `<proceed/>`))
+	if !s.Next() {
+		t.Fatalf("expected proceed: %v", s.Err())
+	}
+	s.Reset(strings.NewReader(streamStart("2") + `<success/></stream:stream>`))
+	if !s.Next() {
+		t.Fatalf("expected success: %v", s.Err())
+	}
+	start, _ := s.Start()
+	if start.Attr[2].Value != "2" {
+		t.Errorf("wrong stream after reset: %+v", start)
+	}
+	if child, _ := s.Current(); child.Name.Local != "success" {
+		t.Errorf("wrong child: want=success, got=%s", child.Name.Local)
+	}
+	if s.Next() || s.Err() != nil {
+		t.Errorf("expected clean close, got err=%v", s.Err())
+	}
+}
+
+var streamReaderErrTestCases = [...]string{
+	0: streamStart("1") + `<message>`,
+	1: streamStart("1"),
+	2: streamStart("1") + `text`,
+	3: `text`,
+	4: `</a>`,
+}
+
+func TestStreamReaderErrors(t *testing.T) {
+	for i, tc := range streamReaderErrTestCases {
+		s := xmlstream.NewStreamReader(strings.NewReader(tc))
+		for s.Next() {
+		}
+		if s.Err() == nil {
+			t.Errorf("%d: expected error", i)
+		}
+	}
+}
+
+func TestStreamWriter(t *testing.T) {
+	var buf strings.Builder
+	start := xml.StartElement{Name: xml.Name{Space: "jabber:client", Local: "stream"}}
+	w := xmlstream.NewStreamWriter(xml.NewEncoder(&buf), start)
+
+	if err := w.EncodeToken(start); err == nil {
+		t.Errorf("expected error writing to unopened stream")
+	}
+	if err := w.Open(); err != nil {
+		t.Fatalf("error opening stream: %v", err)
+	}
+	if err := w.Open(); err == nil {
+		t.Errorf("expected error opening stream twice")
+	}
+	if out := buf.String(); out != `<stream xmlns="jabber:client">` {
+		t.Errorf("stream start not flushed: %s", out)
+	}
+	msg := xml.StartElement{Name: xml.Name{Local: "message"}}
+	if _, err := xmlstream.Copy(w, xmlstream.Wrap(nil, msg)); err != nil {
+		t.Fatalf("error writing message: %v", err)
+	}
+
+	// Restart the stream with a new encoder.
+	if err := w.Flush(); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+	w.Reset(xml.NewEncoder(&buf))
+	if err := w.Open(); err != nil {
+		t.Fatalf("error reopening stream: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("error closing stream: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("error closing stream twice: %v", err)
+	}
+	const want = `<stream xmlns="jabber:client"><message></message><stream xmlns="jabber:client"></stream>`
+	if out := buf.String(); out != want {
+		t.Errorf("wrong output:\nwant=%s,\n got=%s", want, out)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+	"sync"
+)
+
+// SyncTokenWriter is a TokenWriter that is safe for concurrent use and that
+// can write entire elements atomically.
+type SyncTokenWriter struct {
+	w     TokenWriter
+	mu    sync.Mutex
+	busy  bool
+	queue []chan struct{}
+}
+
+// SyncWriter returns a TokenWriter that serializes writes to w.
+//
+// Elements written with ReadXML or Copy are written atomically: tokens written
+// by other goroutines will not be interleaved with them, whether or not the
+// source implements WriterTo.
+// Each call to EncodeToken on the other hand writes a single token, so elements
+// written one token at a time may be interleaved with writes from other
+// goroutines.
+// Concurrent writers are served in the order in which they started waiting.
+// If w implements Flusher, it is flushed after each call to ReadXML so that
+// complete elements do not sit in a buffer.
+//
+// For example, many goroutines can write stanzas to a single connection like
+// so:
+//
+//	w := xmlstream.SyncWriter(xml.NewEncoder(conn))
+//	…
+//	_, err := xmlstream.Copy(w, stanza.TokenReader())
+func SyncWriter(w TokenWriter) *SyncTokenWriter {
+	return &SyncTokenWriter{w: w}
+}
+
+// lock waits until all writers that were already waiting have finished and
+// then locks the underlying writer.
+func (s *SyncTokenWriter) lock() {
+	s.mu.Lock()
+	if !s.busy {
+		s.busy = true
+		s.mu.Unlock()
+		return
+	}
+	c := make(chan struct{})
+	s.queue = append(s.queue, c)
+	s.mu.Unlock()
+	<-c
+}
+
+// unlock hands the underlying writer to the next waiting writer (if any).
+func (s *SyncTokenWriter) unlock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		s.busy = false
+		return
+	}
+	next := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	close(next)
+}
+
+// EncodeToken writes a single token to the underlying writer.
+// It does not flush the underlying writer.
+func (s *SyncTokenWriter) EncodeToken(t xml.Token) error {
+	s.lock()
+	defer s.unlock()
+	return s.w.EncodeToken(t)
+}
+
+// ReadXML writes all tokens from r to the underlying writer without allowing
+// any other writes to be interleaved with them and then flushes the underlying
+// writer if it implements Flusher.
+// If r implements WriterTo its tokens are written directly to the underlying
+// writer.
+func (s *SyncTokenWriter) ReadXML(r xml.TokenReader) (n int, err error) {
+	s.lock()
+	defer s.unlock()
+	n, err = Copy(s.w, r)
+	if err != nil {
+		return n, err
+	}
+	return n, s.flush()
+}
+
+func (s *SyncTokenWriter) flush() error {
+	if f, ok := s.w.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Flush flushes the underlying writer if it implements Flusher.
+func (s *SyncTokenWriter) Flush() error {
+	if _, ok := s.w.(Flusher); !ok {
+		return nil
+	}
+	s.lock()
+	defer s.unlock()
+	return s.flush()
+}
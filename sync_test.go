@@ -0,0 +1,158 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mellium.im/xmlstream"
+)
+
+var (
+	_ xmlstream.TokenWriteFlusher = (*xmlstream.SyncTokenWriter)(nil)
+	_ xmlstream.ReaderFrom        = (*xmlstream.SyncTokenWriter)(nil)
+)
+
+// writerToReader is a TokenReader that also implements WriterTo, so Copy
+// writes its tokens to the destination one at a time with EncodeToken.
+type writerToReader struct {
+	xml.TokenReader
+}
+
+func (r writerToReader) WriteXML(w xmlstream.TokenWriter) (int, error) {
+	var n int
+	for {
+		tok, err := r.Token()
+		if tok != nil {
+			if err := w.EncodeToken(tok); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+var syncWriterAtomicTests = [...]func(w xmlstream.TokenWriter, r xml.TokenReader) error{
+	0: func(w xmlstream.TokenWriter, r xml.TokenReader) error {
+		_, err := xmlstream.Copy(w, r)
+		return err
+	},
+	1: func(w xmlstream.TokenWriter, r xml.TokenReader) error {
+		_, err := xmlstream.Copy(w, writerToReader{r})
+		return err
+	},
+}
+
+func TestSyncWriterAtomic(t *testing.T) {
+	const (
+		writers  = 10
+		elements = 50
+	)
+	for i, write := range syncWriterAtomicTests {
+		write := write
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var buf strings.Builder
+			e := xml.NewEncoder(&buf)
+			w := xmlstream.SyncWriter(e)
+
+			var wg sync.WaitGroup
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					id := strconv.Itoa(i)
+					start := xml.StartElement{Name: xml.Name{Local: "message"}, Attr: []xml.Attr{{Name: xml.Name{Local: "id"}, Value: id}}}
+					for j := 0; j < elements; j++ {
+						r := xmlstream.Wrap(xmlstream.Wrap(xmlstream.Token(xml.CharData(id)), xml.StartElement{Name: xml.Name{Local: "body"}}), start)
+						if err := write(w, r); err != nil {
+							t.Errorf("error writing element: %v", err)
+						}
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			d := xml.NewDecoder(strings.NewReader(buf.String()))
+			var count int
+			for {
+				var msg struct {
+					ID   string `xml:"id,attr"`
+					Body string `xml:"body"`
+				}
+				err := d.Decode(&msg)
+				if err != nil {
+					break
+				}
+				if msg.ID != msg.Body {
+					t.Fatalf("tokens from different elements were interleaved: %+v", msg)
+				}
+				count++
+			}
+			if count != writers*elements {
+				t.Errorf("wrong number of elements: want=%d, got=%d", writers*elements, count)
+			}
+		})
+	}
+}
+
+type flushRecorder struct {
+	xmlstream.TokenWriter
+	flushes int
+}
+
+func (f *flushRecorder) Flush() error {
+	f.flushes++
+	return nil
+}
+
+func TestSyncWriterFlushes(t *testing.T) {
+	rec := &flushRecorder{TokenWriter: xmlstream.Discard()}
+	w := xmlstream.SyncWriter(rec)
+	start := xml.StartElement{Name: xml.Name{Local: "a"}}
+	if err := w.EncodeToken(start); err != nil {
+		t.Fatalf("error encoding token: %v", err)
+	}
+	if rec.flushes != 0 {
+		t.Errorf("EncodeToken should not flush, got %d flushes", rec.flushes)
+	}
+	if _, err := xmlstream.Copy(w, xmlstream.Wrap(nil, start)); err != nil {
+		t.Fatalf("error copying: %v", err)
+	}
+	if rec.flushes != 1 {
+		t.Errorf("expected one flush after copying an element, got %d", rec.flushes)
+	}
+	if err := w.EncodeToken(start.End()); err != nil {
+		t.Fatalf("error encoding token: %v", err)
+	}
+
+	errs := make(chan error)
+	go func() {
+		_, err := xmlstream.Copy(w, xmlstream.Wrap(nil, start))
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Fatalf("error copying: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("another goroutine could not acquire the writer")
+	}
+	if rec.flushes != 2 {
+		t.Errorf("expected two flushes after copying two elements, got %d", rec.flushes)
+	}
+}
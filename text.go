@@ -0,0 +1,139 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TextLimitError is returned by Text and TextOf when the text of an element is
+// longer than the limit.
+type TextLimitError struct {
+	Limit int
+}
+
+func (e *TextLimitError) Error() string {
+	return fmt.Sprintf("xmlstream: element text exceeds limit of %d bytes", e.Limit)
+}
+
+// TextOption is used to configure Text and TextOf.
+type TextOption func(*textOptions)
+
+type textOptions struct {
+	direct bool
+	trim   bool
+}
+
+// TextDirect only collects text that is a direct child of the element and
+// ignores the text of any child elements.
+// By default the text of all descendants is collected.
+func TextDirect() TextOption {
+	return func(o *textOptions) {
+		o.direct = true
+	}
+}
+
+// TextTrim removes leading and trailing whitespace from the text.
+// The limit applies to the text before it is trimmed.
+func TextTrim() TextOption {
+	return func(o *textOptions) {
+		o.trim = true
+	}
+}
+
+// Text consumes the rest of the most recent start element already read from r,
+// including its end element, and returns the character data it contains.
+// Comments, processing instructions, and the names and attributes of child
+// elements are ignored.
+//
+// If limit > 0 and the text is more than limit bytes long, the rest of the
+// element is still consumed and a *TextLimitError is returned.
+func Text(r xml.TokenReader, limit int, opts ...TextOption) (string, error) {
+	return readText(Inner(r), nil, limit, opts)
+}
+
+// TextOf is like Text except that it takes the values returned by the Current
+// method of Iter.
+// If start is nil the child is a single token, and its text is returned if it
+// is character data.
+// Otherwise r is read to the end of the element and an error is returned if the
+// end element does not match start.
+func TextOf(start *xml.StartElement, r xml.TokenReader, limit int, opts ...TextOption) (string, error) {
+	if start != nil {
+		return readText(r, start, limit, opts)
+	}
+	tok, err := r.Token()
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	chars, _ := tok.(xml.CharData)
+	var o textOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o.result(string(chars), limit > 0 && len(chars) > limit, limit)
+}
+
+// readText collects the character data read from r until io.EOF or, if start
+// is not nil, until the end element that matches start.
+func readText(r xml.TokenReader, start *xml.StartElement, limit int, opts []TextOption) (string, error) {
+	var o textOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var b strings.Builder
+	depth := 0
+	over := false
+	for {
+		tok, err := r.Token()
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 && start != nil {
+				if t.Name != start.Name {
+					return "", fmt.Errorf("xmlstream: end element </%s> does not match start element <%s>", t.Name.Local, start.Name.Local)
+				}
+				return o.result(b.String(), over, limit)
+			}
+			depth--
+		case xml.CharData:
+			switch {
+			case over || (o.direct && depth > 0):
+			case limit > 0 && b.Len()+len(t) > limit:
+				over = true
+			default:
+				b.Write(t)
+			}
+		}
+		// Like Copy, treat a nil token without an error as the end of the stream.
+		if err == io.EOF || (tok == nil && err == nil) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if start != nil {
+		return "", io.ErrUnexpectedEOF
+	}
+	return o.result(b.String(), over, limit)
+}
+
+// result returns the text that was collected or an error if it was over the
+// limit.
+func (o textOptions) result(text string, over bool, limit int) (string, error) {
+	if over {
+		return "", &TextLimitError{Limit: limit}
+	}
+	if o.trim {
+		return strings.TrimSpace(text), nil
+	}
+	return text, nil
+}
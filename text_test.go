@@ -0,0 +1,173 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+var textTests = [...]struct {
+	in    string
+	limit int
+	opts  []xmlstream.TextOption
+	out   string
+	err   bool
+}{
+	0: {in: `<body/>`},
+	1: {in: `<body>hi</body>`, out: "hi"},
+	2: {in: `<body>a<b>b<c>c</c></b>d<!-- e --></body>`, out: "abcd"},
+	3: {
+		in:   `<body>a<b>b<c>c</c></b>d</body>`,
+		opts: []xmlstream.TextOption{xmlstream.TextDirect()},
+		out:  "ad",
+	},
+	4: {
+		in:   "<body>\n  hi <b>there</b>\n</body>",
+		opts: []xmlstream.TextOption{xmlstream.TextTrim()},
+		out:  "hi there",
+	},
+	5: {
+		in:   "<body>\n  hi <b>there</b>\n</body>",
+		opts: []xmlstream.TextOption{xmlstream.TextTrim(), xmlstream.TextDirect()},
+		out:  "hi",
+	},
+	6: {in: `<body>hello</body>`, limit: 5, out: "hello"},
+	7: {in: `<body>hel<b>lo!</b></body>`, limit: 5, err: true},
+	8: {
+		in:    `<body>hello<b>world</b></body>`,
+		limit: 5,
+		opts:  []xmlstream.TextOption{xmlstream.TextDirect()},
+		out:   "hello",
+	},
+	9: {in: `<body>&lt;&amp;</body>`, out: "<&"},
+}
+
+func TestText(t *testing.T) {
+	for i, tc := range textTests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			d := xml.NewDecoder(strings.NewReader(tc.in + `<next/>`))
+			if _, err := d.Token(); err != nil {
+				t.Fatalf("error reading start: %v", err)
+			}
+			out, err := xmlstream.Text(d, tc.limit, tc.opts...)
+			var limitErr *xmlstream.TextLimitError
+			switch {
+			case tc.err && !errors.As(err, &limitErr):
+				t.Fatalf("expected limit error, got %v", err)
+			case tc.err && limitErr.Limit != tc.limit:
+				t.Errorf("wrong limit in error: want=%d, got=%d", tc.limit, limitErr.Limit)
+			case !tc.err && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out != tc.out {
+				t.Errorf("wrong text: want=%q, got=%q", tc.out, out)
+			}
+
+			// The element should be consumed even if the limit was exceeded.
+			tok, err := d.Token()
+			if err != nil {
+				t.Fatalf("error reading next token: %v", err)
+			}
+			if start, ok := tok.(xml.StartElement); !ok || start.Name.Local != "next" {
+				t.Errorf("element was not consumed, next token was %v", tok)
+			}
+		})
+	}
+}
+
+func TestTextOf(t *testing.T) {
+	d := xml.NewDecoder(strings.NewReader(`<message>pre<body>hi<b>!</b></body><subject>yo</subject></message>`))
+	if _, err := d.Token(); err != nil {
+		t.Fatalf("error reading start: %v", err)
+	}
+	var got []string
+	iter := xmlstream.NewIter(d)
+	for iter.Next() {
+		start, r := iter.Current()
+		text, err := xmlstream.TextOf(start, r, 0)
+		if err != nil {
+			t.Fatalf("error reading text: %v", err)
+		}
+		got = append(got, text)
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatalf("error iterating: %v", err)
+	}
+	if want := "pre,hi!,yo"; strings.Join(got, ",") != want {
+		t.Errorf("wrong text: want=%q, got=%q", want, strings.Join(got, ","))
+	}
+}
+
+var textOfTests = [...]struct {
+	start *xml.StartElement
+	toks  []xml.Token
+	limit int
+	opts  []xmlstream.TextOption
+	out   string
+	err   bool
+}{
+	0: {toks: []xml.Token{xml.CharData(" hi ")}, out: " hi "},
+	1: {toks: []xml.Token{xml.CharData(" hi ")}, opts: []xmlstream.TextOption{xmlstream.TextTrim()}, out: "hi"},
+	2: {toks: []xml.Token{xml.CharData("hello")}, limit: 2, err: true},
+	3: {toks: []xml.Token{xml.Comment("hi")}, out: ""},
+	4: {
+		start: &xml.StartElement{Name: xml.Name{Local: "a"}},
+		toks:  []xml.Token{xml.CharData("hi"), xml.EndElement{Name: xml.Name{Local: "a"}}},
+		out:   "hi",
+	},
+	5: {
+		start: &xml.StartElement{Name: xml.Name{Local: "a"}},
+		toks:  []xml.Token{xml.CharData("hi"), xml.EndElement{Name: xml.Name{Local: "b"}}},
+		err:   true,
+	},
+	6: {
+		start: &xml.StartElement{Name: xml.Name{Local: "a"}},
+		toks:  []xml.Token{xml.CharData("hi")},
+		err:   true,
+	},
+}
+
+func TestTextOfTokens(t *testing.T) {
+	for i, tc := range textOfTests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			toks := tokenSlice(tc.toks)
+			out, err := xmlstream.TextOf(tc.start, &toks, tc.limit, tc.opts...)
+			switch {
+			case tc.err && err == nil:
+				t.Fatalf("expected error")
+			case !tc.err && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out != tc.out {
+				t.Errorf("wrong text: want=%q, got=%q", tc.out, out)
+			}
+		})
+	}
+}
+
+func TestTextNilToken(t *testing.T) {
+	toks := []xml.Token{xml.CharData("hi")}
+	r := xmlstream.ReaderFunc(func() (xml.Token, error) {
+		if len(toks) == 0 {
+			return nil, nil
+		}
+		tok := toks[0]
+		toks = toks[1:]
+		return tok, nil
+	})
+	out, err := xmlstream.Text(r, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "hi" {
+		t.Errorf("wrong text: want=%q, got=%q", "hi", out)
+	}
+}
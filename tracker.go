@@ -0,0 +1,241 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sync"
+)
+
+var (
+	errDuplicateID = errors.New("xmlstream: a request with that id is already pending")
+	errTrackerDone = errors.New("xmlstream: tracker stopped reading responses")
+)
+
+// Tracker matches responses to requests by the value of their id attribute.
+// A response must have the same name and id as the request and a type
+// attribute of "result" or "error", so a request from the other side that
+// happens to reuse an id is not mistaken for a response.
+//
+// Requests are written with Send, which returns a Call that can be used to wait
+// for the response.
+// Responses are read by Serve, which must be running for calls to complete.
+type Tracker struct {
+	r       xml.TokenReader
+	w       *SyncTokenWriter
+	mu      sync.Mutex
+	pending map[string]trackerCall
+	err     error
+}
+
+// trackerCall is a request that is waiting for a response.
+type trackerCall struct {
+	name xml.Name
+	done chan trackerResult
+}
+
+type trackerResult struct {
+	start xml.StartElement
+	toks  []xml.Token
+	err   error
+}
+
+// NewTracker returns a Tracker that writes requests to w and reads responses
+// from the children of the most recent start element consumed from r, or from
+// the top level elements of r if none has been consumed.
+// Writes to w are serialized using SyncWriter.
+func NewTracker(r xml.TokenReader, w TokenWriter) *Tracker {
+	return &Tracker{
+		r:       r,
+		w:       SyncWriter(w),
+		pending: make(map[string]trackerCall),
+	}
+}
+
+// Call is a request that is waiting for a response.
+type Call struct {
+	// ID is the value of the request's id attribute.
+	ID string
+
+	t    *Tracker
+	done chan trackerResult
+}
+
+// Wait blocks until the response is received, the context is canceled, or the
+// tracker stops reading.
+// The returned reader is limited to the rest of the response, including its end
+// element.
+// If the context is canceled the call is forgotten and a response that arrives
+// later is treated as unsolicited.
+func (c *Call) Wait(ctx context.Context) (xml.StartElement, xml.TokenReader, error) {
+	select {
+	case res := <-c.done:
+		toks := sliceReader(res.toks)
+		return res.start, &toks, res.err
+	case <-ctx.Done():
+		c.t.mu.Lock()
+		if c.t.pending[c.ID].done == c.done {
+			delete(c.t.pending, c.ID)
+		}
+		c.t.mu.Unlock()
+		return xml.StartElement{}, nil, ctx.Err()
+	}
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Send writes a request made up of the start element, the tokens read from
+// payload (which may be nil), and the end element.
+// If start does not have an id attribute a random one is added.
+// The writer is flushed after the request if it is a Flusher.
+// It is safe to call Send from multiple goroutines.
+func (t *Tracker) Send(start xml.StartElement, payload xml.TokenReader) (*Call, error) {
+	var id string
+	found := false
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "" && attr.Name.Local == "id" {
+			id, found = attr.Value, true
+			break
+		}
+	}
+	if !found {
+		var err error
+		id, err = randomID()
+		if err != nil {
+			return nil, err
+		}
+		start = start.Copy()
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "id"}, Value: id})
+	}
+
+	c := &Call{ID: id, t: t, done: make(chan trackerResult, 1)}
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return nil, t.err
+	}
+	if _, ok := t.pending[id]; ok {
+		t.mu.Unlock()
+		return nil, errDuplicateID
+	}
+	t.pending[id] = trackerCall{name: start.Name, done: c.done}
+	t.mu.Unlock()
+
+	if err := t.write(start, payload); err != nil {
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (t *Tracker) write(start xml.StartElement, payload xml.TokenReader) error {
+	if payload == nil {
+		payload = MultiReader()
+	}
+	_, err := t.w.ReadXML(Wrap(payload, start))
+	return err
+}
+
+// Serve reads elements until the end of the parent element or io.EOF and
+// delivers responses to the calls waiting for them.
+// Elements that are not responses to a pending call are passed to h, or
+// skipped if h is nil.
+// The TokenWriter passed to h is shared with Send, so h should write entire
+// elements with Copy to avoid them being interleaved with requests.
+// When Serve returns, any calls that are still waiting fail.
+func (t *Tracker) Serve(h Handler) error {
+	iter := NewIter(t.r)
+	err := t.serve(iter, h)
+	if err == nil {
+		err = iter.Err()
+	}
+
+	fail := err
+	if fail == nil {
+		fail = errTrackerDone
+	}
+	t.mu.Lock()
+	t.err = fail
+	for id, call := range t.pending {
+		call.done <- trackerResult{err: fail}
+		delete(t.pending, id)
+	}
+	t.mu.Unlock()
+
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+func (t *Tracker) serve(iter *Iter, h Handler) error {
+	for iter.Next() {
+		start, r := iter.Current()
+		if start == nil {
+			continue
+		}
+
+		done := t.match(start)
+		if done == nil {
+			if h != nil {
+				if err := h.HandleXML(*start, r, t.w); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		toks, err := ReadAll(r)
+		if err != nil {
+			done <- trackerResult{err: err}
+			return err
+		}
+		done <- trackerResult{start: start.Copy(), toks: toks}
+	}
+	return nil
+}
+
+// match returns the channel of the pending call that start is a response to
+// and forgets the call, or returns nil if start is not a response.
+func (t *Tracker) match(start *xml.StartElement) chan trackerResult {
+	var id, typ string
+	var hasID bool
+	for _, attr := range start.Attr {
+		if attr.Name.Space != "" {
+			continue
+		}
+		switch attr.Name.Local {
+		case "id":
+			id, hasID = attr.Value, true
+		case "type":
+			typ = attr.Value
+		}
+	}
+	if !hasID || (typ != "result" && typ != "error") {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	call, ok := t.pending[id]
+	if !ok || call.name != start.Name {
+		return nil
+	}
+	delete(t.pending, id)
+	return call.done
+}
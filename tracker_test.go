@@ -0,0 +1,161 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"mellium.im/xmlstream"
+)
+
+func iqStart(id string) xml.StartElement {
+	start := xml.StartElement{Name: xml.Name{Local: "iq"}}
+	if id != "" {
+		start.Attr = []xml.Attr{{Name: xml.Name{Local: "id"}, Value: id}}
+	}
+	return start
+}
+
+func TestTracker(t *testing.T) {
+	// The message and the request that reuses id 1 are not responses.
+	const in = `<iq id="2" type="result"><b>two</b></iq><message id="x"/><message id="1" type="result"/><iq id="1" type="get"/><iq id="1" type="error"/>`
+	var buf strings.Builder
+	e := xml.NewEncoder(&buf)
+	tr := xmlstream.NewTracker(xml.NewDecoder(strings.NewReader(in)), e)
+
+	calls := make([]*xmlstream.Call, 0, 4)
+	for _, id := range []string{"1", "2", "3", ""} {
+		c, err := tr.Send(iqStart(id), nil)
+		if err != nil {
+			t.Fatalf("error sending %q: %v", id, err)
+		}
+		calls = append(calls, c)
+	}
+	if _, err := tr.Send(iqStart("1"), nil); err == nil {
+		t.Errorf("expected error sending duplicate id")
+	}
+	gen := calls[3].ID
+	if gen == "" {
+		t.Errorf("expected an id to be generated")
+	}
+	want := fmt.Sprintf(`<iq id="1"></iq><iq id="2"></iq><iq id="3"></iq><iq id="%s"></iq>`, gen)
+	if out := buf.String(); out != want {
+		t.Errorf("wrong requests:\nwant=%s,\n got=%s", want, out)
+	}
+
+	// Wait for the third call with a context that is already canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := calls[2].Wait(ctx); err != context.Canceled {
+		t.Errorf("wrong error for canceled context: want=%v, got=%v", context.Canceled, err)
+	}
+
+	var unsolicited []string
+	err := tr.Serve(xmlstream.HandlerFunc(func(start xml.StartElement, _ xml.TokenReader, _ xmlstream.TokenWriter) error {
+		unsolicited = append(unsolicited, start.Name.Local)
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("error serving: %v", err)
+	}
+	if s := strings.Join(unsolicited, " "); s != "message message iq" {
+		t.Errorf("wrong unsolicited elements: want=message message iq, got=%s", s)
+	}
+
+	start, r, err := calls[1].Wait(context.Background())
+	if err != nil {
+		t.Fatalf("error waiting for response: %v", err)
+	}
+	toks, err := xmlstream.ReadAll(r)
+	if err != nil {
+		t.Fatalf("error reading response: %v", err)
+	}
+	if start.Attr[0].Value != "2" || len(toks) != 4 {
+		t.Errorf("wrong response: %+v %+v", start, toks)
+	}
+	start, _, err = calls[0].Wait(context.Background())
+	if err != nil {
+		t.Errorf("error waiting for first response: %v", err)
+	}
+	if start.Attr[1].Value != "error" {
+		t.Errorf("wrong response to first call: %+v", start)
+	}
+
+	// The generated id never received a response.
+	if _, _, err := calls[3].Wait(context.Background()); err == nil {
+		t.Errorf("expected error after Serve returned")
+	}
+	if _, err := tr.Send(iqStart(""), nil); err == nil {
+		t.Errorf("expected error sending after Serve returned")
+	}
+}
+
+func TestTrackerConcurrent(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+
+	// Echo each request back as a response with the same id.
+	go func() {
+		d := xml.NewDecoder(reqR)
+		e := xml.NewEncoder(respW)
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				respW.CloseWithError(err)
+				return
+			}
+			if start, ok := tok.(xml.StartElement); ok {
+				resp := start.Copy()
+				resp.Attr = append(resp.Attr, xml.Attr{Name: xml.Name{Local: "type"}, Value: "result"})
+				if _, err := xmlstream.Copy(e, xmlstream.Wrap(nil, resp)); err != nil {
+					respW.CloseWithError(err)
+					return
+				}
+				if err := e.Flush(); err != nil {
+					respW.CloseWithError(err)
+					return
+				}
+			}
+		}
+	}()
+
+	tr := xmlstream.NewTracker(xml.NewDecoder(respR), xml.NewEncoder(reqW))
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- tr.Serve(nil)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := tr.Send(iqStart(""), nil)
+			if err != nil {
+				t.Errorf("error sending: %v", err)
+				return
+			}
+			start, _, err := c.Wait(context.Background())
+			if err != nil {
+				t.Errorf("error waiting: %v", err)
+				return
+			}
+			if start.Name.Local != "iq" || start.Attr[0].Value != c.ID {
+				t.Errorf("wrong response for %s: %+v", c.ID, start)
+			}
+		}()
+	}
+	wg.Wait()
+	reqW.Close()
+	if err := <-serveErr; err != nil {
+		t.Errorf("unexpected error serving: %v", err)
+	}
+}
@@ -5,18 +5,10 @@
 package xmlstream
 
 import (
-	"encoding/xml"
 	"unicode"
 	"unicode/utf8"
 )
 
-var whitespaceRemover = Remove(func(t xml.Token) bool {
-	if chars, ok := t.(xml.CharData); ok && isWhitespace(chars) {
-		return true
-	}
-	return false
-})
-
 // TODO: Make a dummy text.Transformer instead?
 func isWhitespace(b []byte) bool {
 	for r, size := utf8.DecodeRune(b); r != utf8.RuneError; r, size = utf8.DecodeRune(b) {
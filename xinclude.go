@@ -0,0 +1,454 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// XIncludeNS is the namespace used by XInclude elements.
+const XIncludeNS = "http://www.w3.org/2001/XInclude"
+
+var (
+	errXIncludeLoop  = errors.New("xmlstream: xinclude loop detected")
+	errXIncludeDepth = errors.New("xmlstream: xinclude depth limit exceeded")
+)
+
+// XIncludeOption configures the XInclude transformer.
+type XIncludeOption func(*xincluder)
+
+// XIncludeBase sets the name of the document being transformed in the file
+// system.
+// Relative hrefs are resolved against it and it is used to detect documents
+// that include themselves.
+// By default hrefs are resolved relative to the root of the file system.
+func XIncludeBase(name string) XIncludeOption {
+	return func(x *xincluder) {
+		x.base = name
+	}
+}
+
+// XIncludeMaxDepth sets the maximum number of nested inclusions.
+// The default is 16.
+func XIncludeMaxDepth(n int) XIncludeOption {
+	return func(x *xincluder) {
+		x.max = n
+	}
+}
+
+// XInclude returns a transformer that replaces XInclude include elements with
+// the resources they reference, which are read from fsys.
+// The readers returned by the transformer are created with XIncludeReader, so
+// they implement TokenReadCloser.
+func XInclude(fsys fs.FS, opts ...XIncludeOption) Transformer {
+	return func(r xml.TokenReader) xml.TokenReader {
+		return XIncludeReader(r, fsys, opts...)
+	}
+}
+
+// XIncludeReader returns a reader that replaces XInclude include elements read
+// from r with the resources they reference, which are read from fsys.
+//
+// Both parse="xml" (the default) and parse="text" are supported, and for XML
+// resources the xpointer attribute may contain shorthand pointers and pointers
+// using the element() scheme.
+// Because names are resolved against the xml:id attribute only elements with
+// an xml:id can be found by name.
+// Other pointer schemes are ignored.
+// If a resource cannot be read or the pointer does not identify an element,
+// the children of the include element's fallback element are used instead; if
+// there is no fallback it is an error.
+// Included documents and fallbacks are themselves processed for XInclude
+// elements, and including a resource that is already being included or
+// exceeding the maximum depth is an error.
+//
+// Included documents are streamed unless the xpointer attribute is used, in
+// which case they are read into memory.
+// Each file is closed once it has been read, and Close closes any files that
+// are still open, for example because reading stopped early or failed.
+// Close does not close r.
+// Same document references, xml:base fixup, and language fixup are not
+// supported.
+func XIncludeReader(r xml.TokenReader, fsys fs.FS, opts ...XIncludeOption) TokenReadCloser {
+	x := &xincluder{fsys: fsys, max: 16}
+	for _, opt := range opts {
+		opt(x)
+	}
+	var stack []string
+	if x.base != "" {
+		stack = []string{x.base + "#"}
+	}
+	return &xincludeReader{x: x, r: r, base: x.base, stack: stack}
+}
+
+type xincluder struct {
+	fsys fs.FS
+	base string
+	max  int
+}
+
+// resourceError is an error that causes the fallback to be used.
+type resourceError struct {
+	err error
+}
+
+func (e resourceError) Error() string { return e.err.Error() }
+func (e resourceError) Unwrap() error { return e.err }
+
+type xincludeReader struct {
+	x     *xincluder
+	r     xml.TokenReader
+	base  string
+	stack []string
+	// file is the file being read, if any, and inc is the most recent inclusion.
+	// Inclusions are read to the end before the rest of r, so only the most
+	// recent one may still be open.
+	file io.Closer
+	inc  io.Closer
+}
+
+func (x *xincludeReader) Token() (xml.Token, error) {
+	for {
+		tok, err := x.r.Token()
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name != (xml.Name{Space: XIncludeNS, Local: "include"}) || err != nil {
+			return tok, err
+		}
+		inc, err := x.include(start)
+		if err != nil {
+			return nil, err
+		}
+		x.inc, _ = inc.(io.Closer)
+		x.r = MultiReader(inc, x.r)
+	}
+}
+
+// Close closes the files that are still open.
+func (x *xincludeReader) Close() error {
+	var errs []error
+	if x.inc != nil {
+		errs = append(errs, x.inc.Close())
+		x.inc = nil
+	}
+	if x.file != nil {
+		errs = append(errs, x.file.Close())
+		x.file = nil
+	}
+	return errors.Join(errs...)
+}
+
+// child returns a reader that processes XInclude elements in r and closes file
+// (which may be nil) when it is closed.
+func (x *xincludeReader) child(r xml.TokenReader, base string, stack []string, file io.Closer) *xincludeReader {
+	return &xincludeReader{x: x.x, r: r, base: base, stack: stack, file: file}
+}
+
+func (x *xincludeReader) include(start xml.StartElement) (xml.TokenReader, error) {
+	var href, parse, xpointer string
+	for _, attr := range start.Attr {
+		if attr.Name.Space != "" {
+			continue
+		}
+		switch attr.Name.Local {
+		case "href":
+			href = attr.Value
+		case "parse":
+			parse = attr.Value
+		case "xpointer":
+			xpointer = attr.Value
+		}
+	}
+	switch {
+	case parse == "":
+		parse = "xml"
+	case parse != "xml" && parse != "text":
+		return nil, fmt.Errorf("xmlstream: invalid xinclude parse attribute %q", parse)
+	}
+	if href == "" && xpointer == "" {
+		return nil, errors.New("xmlstream: xinclude element must have an href or xpointer")
+	}
+	if parse == "text" && xpointer != "" {
+		return nil, errors.New("xmlstream: xinclude xpointer cannot be used with parse=\"text\"")
+	}
+
+	children, err := ReadAll(InnerElement(x.r))
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := x.resource(href, parse, xpointer)
+	var resErr resourceError
+	if errors.As(err, &resErr) {
+		fb, ok := fallback(children)
+		if !ok {
+			return nil, resErr.err
+		}
+		return x.child(&fb, x.base, x.stack, nil), nil
+	}
+	return r, err
+}
+
+// fallback returns the children of the first fallback element in toks, which
+// contains the children of an include element.
+func fallback(toks []xml.Token) (sliceReader, bool) {
+	depth := 0
+	begin := -1
+	for i, tok := range toks {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 && begin == -1 && t.Name == (xml.Name{Space: XIncludeNS, Local: "fallback"}) {
+				begin = i + 1
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == 0 && begin != -1 {
+				return sliceReader(toks[begin:i]), true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (x *xincludeReader) resource(href, parse, xpointer string) (xml.TokenReader, error) {
+	if href == "" {
+		return nil, resourceError{errors.New("xmlstream: same document xinclude references are not supported")}
+	}
+	name := path.Join(path.Dir(x.base), href)
+	if !fs.ValidPath(name) {
+		return nil, resourceError{fmt.Errorf("xmlstream: invalid xinclude href %q", href)}
+	}
+
+	if parse == "text" {
+		b, err := fs.ReadFile(x.x.fsys, name)
+		if err != nil {
+			return nil, resourceError{err}
+		}
+		if !utf8.Valid(b) {
+			return nil, resourceError{fmt.Errorf("xmlstream: xinclude text resource %q is not valid UTF-8", name)}
+		}
+		return Token(xml.CharData(b)), nil
+	}
+
+	key := name + "#" + xpointer
+	for _, s := range x.stack {
+		if s == key {
+			return nil, errXIncludeLoop
+		}
+	}
+	if len(x.stack) >= x.x.max {
+		return nil, errXIncludeDepth
+	}
+
+	f, err := x.x.fsys.Open(name)
+	if err != nil {
+		return nil, resourceError{err}
+	}
+	d := &closeReader{r: xml.NewDecoder(f), c: f}
+	depth := 0
+	// Only include the document's information items: not the XML declaration,
+	// the document type declaration, or whitespace outside of the root element.
+	var r xml.TokenReader = ReaderFunc(func() (xml.Token, error) {
+		for {
+			tok, err := d.Token()
+			switch t := tok.(type) {
+			case xml.StartElement:
+				depth++
+			case xml.EndElement:
+				depth--
+			case xml.ProcInst:
+				if t.Target == "xml" && err == nil {
+					continue
+				}
+			case xml.CharData, xml.Directive:
+				if depth == 0 && err == nil {
+					continue
+				}
+			}
+			return tok, err
+		}
+	})
+
+	if xpointer != "" {
+		toks, err := ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		sel, ok := xpointerSelect(toks, xpointer)
+		if !ok {
+			return nil, resourceError{fmt.Errorf("xmlstream: xpointer %q did not identify an element in %q", xpointer, name)}
+		}
+		r = &sel
+	}
+
+	stack := make([]string, len(x.stack), len(x.stack)+1)
+	copy(stack, x.stack)
+	return x.child(r, name, append(stack, key), d), nil
+}
+
+// closeReader closes c when r returns an error or io.EOF, or when it is closed.
+type closeReader struct {
+	r xml.TokenReader
+	c io.Closer
+}
+
+func (r *closeReader) Token() (xml.Token, error) {
+	tok, err := r.r.Token()
+	if err != nil {
+		r.Close()
+	}
+	return tok, err
+}
+
+func (r *closeReader) Close() error {
+	if r.c == nil {
+		return nil
+	}
+	err := r.c.Close()
+	r.c = nil
+	return err
+}
+
+// xpointerSelect returns the tokens of the element identified by the first
+// part of the pointer that identifies an element.
+func xpointerSelect(toks []xml.Token, pointer string) (sliceReader, bool) {
+	for _, data := range xpointerParts(pointer) {
+		id, seq, ok := parseElementScheme(data)
+		if !ok {
+			continue
+		}
+		if sel, ok := selectElement(toks, id, seq); ok {
+			return sel, true
+		}
+	}
+	return nil, false
+}
+
+// xpointerParts returns the data of each element() scheme part in the pointer,
+// treating a shorthand pointer as element(name).
+func xpointerParts(pointer string) []string {
+	pointer = strings.TrimSpace(pointer)
+	if !strings.Contains(pointer, "(") {
+		return []string{pointer}
+	}
+	var parts []string
+	for pointer != "" {
+		open := strings.IndexByte(pointer, '(')
+		if open == -1 {
+			break
+		}
+		scheme := strings.TrimSpace(pointer[:open])
+		var data strings.Builder
+		depth := 1
+		i := open + 1
+		for ; i < len(pointer) && depth > 0; i++ {
+			c := pointer[i]
+			switch {
+			case c == '^' && i+1 < len(pointer):
+				i++
+				data.WriteByte(pointer[i])
+				continue
+			case c == '(':
+				depth++
+			case c == ')':
+				depth--
+				if depth == 0 {
+					continue
+				}
+			}
+			data.WriteByte(c)
+		}
+		if scheme == "element" {
+			parts = append(parts, data.String())
+		}
+		pointer = pointer[i:]
+	}
+	return parts
+}
+
+// parseElementScheme parses the data of an element() pointer part, which is an
+// optional name followed by a child sequence such as /1/2.
+func parseElementScheme(data string) (id string, seq []int, ok bool) {
+	steps := strings.Split(data, "/")
+	id = steps[0]
+	for _, step := range steps[1:] {
+		n, err := strconv.Atoi(step)
+		if err != nil || n < 1 {
+			return "", nil, false
+		}
+		seq = append(seq, n)
+	}
+	return id, seq, id != "" || len(seq) > 0
+}
+
+// selectElement returns the tokens of the element identified by a child
+// sequence, relative to the element with the given xml:id if id is not empty.
+func selectElement(toks []xml.Token, id string, seq []int) (sliceReader, bool) {
+	var target []int
+	if id == "" {
+		target = seq
+	}
+	counts := []int{0}
+	var pos []int
+	for i, tok := range toks {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			counts[len(counts)-1]++
+			pos = append(pos, counts[len(counts)-1])
+			counts = append(counts, 0)
+			if target == nil && hasXMLID(t, id) {
+				target = append(append([]int{}, pos...), seq...)
+			}
+			if target != nil && equalPath(pos, target) {
+				depth := 0
+				for j := i; j < len(toks); j++ {
+					switch toks[j].(type) {
+					case xml.StartElement:
+						depth++
+					case xml.EndElement:
+						depth--
+						if depth == 0 {
+							return sliceReader(toks[i : j+1]), true
+						}
+					}
+				}
+				return nil, false
+			}
+		case xml.EndElement:
+			counts = counts[:len(counts)-1]
+			pos = pos[:len(pos)-1]
+		}
+	}
+	return nil, false
+}
+
+func hasXMLID(start xml.StartElement, id string) bool {
+	for _, attr := range start.Attr {
+		if attr.Name.Space == xmlNS && attr.Name.Local == "id" && attr.Value == id {
+			return true
+		}
+	}
+	return false
+}
+
+func equalPath(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+// Copyright 2026 The Mellium Contributors.
+// Use of this source code is governed by the BSD 2-clause
+// license that can be found in the LICENSE file.
+
+package xmlstream_test
+
+import (
+	"encoding/xml"
+	"io/fs"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"mellium.im/xmlstream"
+)
+
+var xincludeFS = fstest.MapFS{
+	"a.xml":        {Data: []byte("<?xml version=\"1.0\"?>\n<!DOCTYPE chapter>\n<chapter><title>A</title></chapter>\n")},
+	"text.txt":     {Data: []byte("plain & text")},
+	"ids.xml":      {Data: []byte(`<doc><sec xml:id="s1"><p>one</p><p>two</p></sec><sec><p>three</p></sec></doc>`)},
+	"loop.xml":     {Data: []byte(`<l><xi:include xmlns:xi="http://www.w3.org/2001/XInclude" href="loop.xml"/></l>`)},
+	"nested/b.xml": {Data: []byte(`<b><xi:include xmlns:xi="http://www.w3.org/2001/XInclude" href="c.xml"/></b>`)},
+	"nested/c.xml": {Data: []byte(`<c/>`)},
+}
+
+func xinclude(attrs, children string) string {
+	return `<r><xi:include xmlns:xi="http://www.w3.org/2001/XInclude" ` + attrs + `>` + children + `</xi:include></r>`
+}
+
+const xiFallback = `<xi:fallback><em>missing</em></xi:fallback>`
+
+var xincludeTestCases = [...]struct {
+	in   string
+	opts []xmlstream.XIncludeOption
+	out  string
+	err  bool
+}{
+	0: {in: `<r/>`, out: `<r></r>`},
+	1: {in: xinclude(`href="a.xml"`, ""), out: `<r><chapter><title>A</title></chapter></r>`},
+	2: {in: xinclude(`href="text.txt" parse="text"`, ""), out: `<r>plain &amp; text</r>`},
+	3: {in: xinclude(`href="ids.xml" xpointer="element(/1/2)"`, ""), out: `<r><sec><p>three</p></sec></r>`},
+	4: {in: xinclude(`href="ids.xml" xpointer="element(s1/2)"`, ""), out: `<r><p>two</p></r>`},
+	5: {in: xinclude(`href="ids.xml" xpointer="foo(b^)ar)element(/1/2/1)"`, ""), out: `<r><p>three</p></r>`},
+	6: {in: xinclude(`href="missing.xml"`, xiFallback), out: `<r><em>missing</em></r>`},
+	7: {in: xinclude(`href="ids.xml" xpointer="element(/1/5)"`, xiFallback), out: `<r><em>missing</em></r>`},
+	8: {in: xinclude(`href="missing.xml"`, ""), err: true},
+	9: {in: xinclude(`href="loop.xml"`, ""), err: true},
+	10: {
+		in:   `<l><xi:include xmlns:xi="http://www.w3.org/2001/XInclude" href="loop.xml"/></l>`,
+		opts: []xmlstream.XIncludeOption{xmlstream.XIncludeBase("loop.xml")},
+		err:  true,
+	},
+	11: {in: xinclude(`href="nested/b.xml"`, ""), out: `<r><b><c></c></b></r>`},
+	12: {in: xinclude(`href="nested/b.xml"`, ""), opts: []xmlstream.XIncludeOption{xmlstream.XIncludeMaxDepth(1)}, err: true},
+	13: {in: xinclude(`href="a.xml" parse="html"`, ""), err: true},
+	14: {in: xinclude(`href="missing.xml"`, `<xi:fallback><xi:include href="text.txt" parse="text"/></xi:fallback>`), out: `<r>plain &amp; text</r>`},
+	15: {in: xinclude(`href="../a.xml"`, xiFallback), out: `<r><em>missing</em></r>`},
+}
+
+var _ xmlstream.TokenReadCloser = xmlstream.XIncludeReader(nil, xincludeFS)
+
+func TestXInclude(t *testing.T) {
+	for i, tc := range xincludeTestCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r := xmlstream.XInclude(xincludeFS, tc.opts...)(xml.NewDecoder(strings.NewReader(tc.in)))
+			var buf strings.Builder
+			w := xmlstream.XMLWriter(&buf)
+			_, err := xmlstream.Copy(w, r)
+			switch {
+			case tc.err && err == nil:
+				t.Fatalf("expected error")
+			case tc.err:
+				return
+			case err != nil:
+				t.Fatalf("error copying: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("error closing: %v", err)
+			}
+			if out := buf.String(); out != tc.out {
+				t.Errorf("wrong output:\nwant=%s,\n got=%s", tc.out, out)
+			}
+		})
+	}
+}
+
+// countFS counts the files that are open.
+type countFS struct {
+	fs.FS
+	open int
+}
+
+func (c *countFS) Open(name string) (fs.File, error) {
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	c.open++
+	return &countFile{File: f, fsys: c}, nil
+}
+
+type countFile struct {
+	fs.File
+	fsys   *countFS
+	closed bool
+}
+
+func (f *countFile) Close() error {
+	if !f.closed {
+		f.closed = true
+		f.fsys.open--
+	}
+	return f.File.Close()
+}
+
+var xincludeCloseTestCases = [...]struct {
+	in string
+	// Read n tokens, or until an error if n is negative.
+	n    int
+	open int
+}{
+	0: {in: xinclude(`href="nested/b.xml"`, ""), n: -1, open: 0},
+	1: {in: xinclude(`href="nested/b.xml"`, ""), n: 3, open: 2},
+	2: {in: xinclude(`href="nested/b.xml"`, ""), n: 2, open: 1},
+	3: {in: xinclude(`href="loop.xml"`, ""), n: -1, open: 1},
+}
+
+func TestXIncludeClose(t *testing.T) {
+	for i, tc := range xincludeCloseTestCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			fsys := &countFS{FS: xincludeFS}
+			r := xmlstream.XIncludeReader(xml.NewDecoder(strings.NewReader(tc.in)), fsys)
+			for n := 0; tc.n < 0 || n < tc.n; n++ {
+				tok, err := r.Token()
+				if err != nil || tok == nil {
+					break
+				}
+			}
+			if fsys.open != tc.open {
+				t.Errorf("wrong number of open files before closing: want=%d, got=%d", tc.open, fsys.open)
+			}
+			if err := r.Close(); err != nil {
+				t.Fatalf("error closing: %v", err)
+			}
+			if fsys.open != 0 {
+				t.Errorf("%d files still open after closing", fsys.open)
+			}
+		})
+	}
+}